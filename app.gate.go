@@ -0,0 +1,153 @@
+package road
+
+import (
+	"fmt"
+	"github.com/lixianmin/logo"
+	"github.com/lixianmin/road/route"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+线上出问题时, 可以在运行时关闭某个route(比如"shop.buy")或者整个service, 而不需要踢掉session:
+1. 被关闭的request会收到指定的错误码与错误信息, notify直接丢弃
+2. 加入allowlist的session(比如GM)不受限制, 可以用来验证修复的结果
+3. 与Unregister()不同, 被关闭的route仍然保留handler, Enable之后立即恢复
+
+gate规则与handlerTable一样是copy-on-write的只读快照, 每个请求只需要两次map查询
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type gateTable struct {
+	routes   map[string]*Error // key为route.Short(), 比如"shop.buy"
+	services map[string]*Error
+}
+
+func newGateTable() *gateTable {
+	return &gateTable{
+		routes:   make(map[string]*Error),
+		services: make(map[string]*Error),
+	}
+}
+
+func (my *gateTable) clone() *gateTable {
+	var next = &gateTable{
+		routes:   make(map[string]*Error, len(my.routes)),
+		services: make(map[string]*Error, len(my.services)),
+	}
+
+	for key, err := range my.routes {
+		next.routes[key] = err
+	}
+
+	for key, err := range my.services {
+		next.services[key] = err
+	}
+
+	return next
+}
+
+// DisableRoute 关闭一个route, err为nil时使用ErrRouteDisabled. route不存在时返回错误, 避免拼错的名字悄悄地不生效
+func (my *App) DisableRoute(name string, err *Error) error {
+	var rt, err1 = route.Decode(name)
+	if err1 != nil {
+		return err1
+	}
+
+	var key = rt.Short()
+	if _, ok := my.loadTable().handlers[key]; !ok {
+		return fmt.Errorf("handler: route not found: %s", key)
+	}
+
+	my.updateGates(func(table *gateTable) {
+		table.routes[key] = checkGateError(err)
+	})
+
+	logo.Info("route=%s is disabled", key)
+	return nil
+}
+
+// EnableRoute 重新开启通过DisableRoute()关闭的route
+func (my *App) EnableRoute(name string) error {
+	var rt, err = route.Decode(name)
+	if err != nil {
+		return err
+	}
+
+	var key = rt.Short()
+	my.updateGates(func(table *gateTable) {
+		delete(table.routes, key)
+	})
+
+	logo.Info("route=%s is enabled", key)
+	return nil
+}
+
+// DisableService 关闭一个service下所有的route, err为nil时使用ErrRouteDisabled. service不存在时返回错误
+func (my *App) DisableService(name string, err *Error) error {
+	if !my.loadTable().hasService(name) {
+		return fmt.Errorf("handler: service not found: %s", name)
+	}
+
+	my.updateGates(func(table *gateTable) {
+		table.services[name] = checkGateError(err)
+	})
+
+	logo.Info("service=%s is disabled", name)
+	return nil
+}
+
+// EnableService 重新开启通过DisableService()关闭的service, 通过DisableRoute()单独关闭的route不受影响
+func (my *App) EnableService(name string) {
+	my.updateGates(func(table *gateTable) {
+		delete(table.services, name)
+	})
+
+	logo.Info("service=%s is enabled", name)
+}
+
+// AddGateAllowlist 加入allowlist的session可以访问被关闭的route, session关闭时自动移出allowlist
+func (my *App) AddGateAllowlist(sessionId int64) {
+	my.gateAllowlist.Put(sessionId, struct{}{})
+}
+
+// RemoveGateAllowlist 把session移出allowlist
+func (my *App) RemoveGateAllowlist(sessionId int64) {
+	my.gateAllowlist.Remove(sessionId)
+}
+
+func (my *App) updateGates(update func(table *gateTable)) {
+	my.gateLock.Lock()
+	defer my.gateLock.Unlock()
+
+	var next = my.gates.Load().(*gateTable).clone()
+	update(next)
+	my.gates.Store(next)
+}
+
+// checkGate 返回nil表示允许访问. 只有route被关闭时才需要查询allowlist
+func (my *App) checkGate(sessionId int64, rt *route.Route) error {
+	var table = my.gates.Load().(*gateTable)
+	var err, ok = table.routes[rt.Short()]
+	if !ok {
+		if err, ok = table.services[rt.Service]; !ok {
+			return nil
+		}
+	}
+
+	if _, allowed := my.gateAllowlist.Get2(sessionId); allowed {
+		return nil
+	}
+
+	return err
+}
+
+func checkGateError(err *Error) *Error {
+	if err == nil {
+		return ErrRouteDisabled
+	}
+
+	return err
+}
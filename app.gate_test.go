@@ -0,0 +1,49 @@
+package road
+
+import (
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestDisableUnknownNames(t *testing.T) {
+	var app, _ = newTestApp(t)
+	handleStrictEnter(t, app)
+
+	var cases = []struct {
+		name      string
+		isService bool
+		isValid   bool
+	}{
+		{name: "room.Enter", isValid: true},
+		{name: "room.Leave"},
+		{name: "strict.Enter", isValid: true},
+		{name: "room", isService: true, isValid: true},
+		{name: "strict", isService: true, isValid: true},
+		{name: "shop", isService: true},
+		{name: "str", isService: true},
+	}
+
+	for _, item := range cases {
+		var err error
+		if item.isService {
+			err = app.DisableService(item.name, nil)
+		} else {
+			err = app.DisableRoute(item.name, nil)
+		}
+
+		if (err == nil) != item.isValid {
+			t.Errorf("%s: isValid=%v, got err=%v", item.name, item.isValid, err)
+		}
+	}
+
+	var gates = app.gates.Load().(*gateTable)
+	if len(gates.routes) != 2 || len(gates.services) != 2 {
+		t.Fatalf("unknown names should not be gated, routes=%v services=%v", gates.routes, gates.services)
+	}
+}
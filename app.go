@@ -1,6 +1,7 @@
 package road
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/lixianmin/got/loom"
@@ -15,6 +16,9 @@ import (
 	"github.com/lixianmin/road/route"
 	"github.com/lixianmin/road/serialize"
 	"github.com/lixianmin/road/util/compression"
+	"github.com/lixianmin/road/validate"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,7 +33,7 @@ type (
 	HookFunc func(rawMethod func() (interface{}, error)) (interface{}, error)
 	App      struct {
 		// 下面这组参数，有session里都会用到
-		handlers              map[string]*component.Handler // all handler method
+		table                 atomic.Value // *handlerTable, 所有的handler, 修改时copy-on-write
 		packetEncoder         codec.PacketEncoder
 		packetDecoder         codec.PacketDecoder
 		messageEncoder        message.Encoder
@@ -39,22 +43,46 @@ type (
 		heartbeatPacketData   []byte
 		handshakeResponseData []byte
 		sendingChanSize       int
-		taskQueueSize         int
+		queueMaxMessages      int                      // session发送队列的消息数上限
+		queueMaxBytes         int                      // session发送队列的字节数上限
+		queuePolicy           OverflowPolicy           // session发送队列超出上限时的策略
+		taskQueueSize         int                      // session任务队列的长度, 为0时不开启
+		taskBudget            time.Duration            // session任务执行超过这个时间时打印警告日志
+		handlerTimeout        time.Duration            // handler的默认超时时间, 为0时不限制
+		routeTimeouts         map[string]time.Duration // 按route设置的handler超时时间, 优先于handlerTimeout
+		argValidation         bool                     // 是否根据validate tag校验handler的参数
+		errorPolicy           ErrorPolicy              // handler返回的错误如何发给client
+		nodeId                string                   // RequestId的前缀
+		shutdownTimeout       time.Duration            // Close()时等待session发送队列清空, 以及移除service时等待handler返回的最长时间
 		rateLimitBySecond     int32
 
-		accept   epoll.Acceptor
+		ctx      context.Context // 所有session的ctx都派生自它, App关闭时取消
+		cancel   context.CancelFunc
+		connChan chan acceptedConn
 		sessions loom.Map
 		senders  []*sessionSender
 		tasks    *taskx.Queue
 		wc       loom.WaitClose
 
-		services     map[string]*component.Service // all registered service
 		hookCallback HookFunc
+
+		tableLock     sync.Mutex   // 串行化对table的修改
+		gates         atomic.Value // *gateTable, 被关闭的route与service
+		gateLock      sync.Mutex
+		gateAllowlist loom.Map // 不受gates限制的session
+		lifecycleLock sync.Mutex
+		components    []*component.Service // 按注册顺序排列, 用于调用生命周期方法
+		started       bool
 	}
 
 	appFetus struct {
 		onHandShakenHandlers []func(session Session)
 	}
+
+	acceptedConn struct {
+		kind string
+		conn epoll.PlayerConn
+	}
 )
 
 func NewApp(accept epoll.Acceptor, opts ...AppOption) *App {
@@ -64,6 +92,18 @@ func NewApp(accept epoll.Acceptor, opts ...AppOption) *App {
 		DataCompression:          false,
 		SenderBufferSize:         4096,
 		SenderCount:              16,
+		SenderMaxBatchSize:       64 * 1024,
+		SenderMaxDelay:           0,
+		SessionQueueMaxMessages:  1024,
+		SessionQueueMaxBytes:     4 * 1024 * 1024,
+		SessionQueuePolicy:       OverflowDisconnect,
+		SessionTaskQueueSize:     0,
+		SessionTaskBudget:        100 * time.Millisecond,
+		HandlerTimeout:           0,
+		RouteTimeouts:            make(map[string]time.Duration),
+		ErrorPolicy:              ErrorPolicyExpose,
+		NodeId:                   defaultNodeId,
+		ShutdownTimeout:          3 * time.Second,
 		SessionRateLimitBySecond: 2,
 	}
 
@@ -73,7 +113,6 @@ func NewApp(accept epoll.Acceptor, opts ...AppOption) *App {
 	}
 
 	var app = &App{
-		handlers:          make(map[string]*component.Handler, 8),
 		packetDecoder:     codec.NewPomeloPacketDecoder(),
 		packetEncoder:     codec.NewPomeloPacketEncoder(),
 		messageEncoder:    message.NewMessagesEncoder(options.DataCompression),
@@ -82,14 +121,27 @@ func NewApp(accept epoll.Acceptor, opts ...AppOption) *App {
 		heartbeatInterval: options.HeartbeatInterval,
 		sendingChanSize:   options.SenderBufferSize,
 		rateLimitBySecond: int32(options.SessionRateLimitBySecond),
-
-		accept:   accept,
-		services: make(map[string]*component.Service),
+		queueMaxMessages:  options.SessionQueueMaxMessages,
+		queueMaxBytes:     options.SessionQueueMaxBytes,
+		queuePolicy:       options.SessionQueuePolicy,
+		taskQueueSize:     options.SessionTaskQueueSize,
+		taskBudget:        options.SessionTaskBudget,
+		handlerTimeout:    options.HandlerTimeout,
+		routeTimeouts:     options.RouteTimeouts,
+		argValidation:     options.ArgValidation,
+		errorPolicy:       options.ErrorPolicy,
+		nodeId:            options.NodeId,
+		shutdownTimeout:   options.ShutdownTimeout,
+
+		connChan: make(chan acceptedConn, 16),
 		hookCallback: func(rawMethod func() (interface{}, error)) (i interface{}, e error) {
 			return rawMethod()
 		},
 	}
 
+	app.ctx, app.cancel = context.WithCancel(context.Background())
+	app.table.Store(newHandlerTable())
+	app.gates.Store(newGateTable())
 	app.senders = createSenders(options)
 	app.heartbeatPacketData = app.encodeHeartbeatData()
 	app.handshakeResponseData = app.encodeHandshakeData(options.DataCompression)
@@ -98,17 +150,64 @@ func NewApp(accept epoll.Acceptor, opts ...AppOption) *App {
 	app.tasks = taskx.NewQueue(taskx.WithSize(2), taskx.WithCloseChan(app.wc.C()))
 
 	loom.Go(app.goLoop)
+	app.AddAcceptor(accept)
 	return app
 }
 
+// AddAcceptor 可以在运行时继续添加acceptor, 比如同时服务tcp与websocket. 所有的acceptor共享同一份handlers与sessions
+func (my *App) AddAcceptor(accept epoll.Acceptor) {
+	if accept == nil {
+		return
+	}
+
+	loom.Go(func(later loom.Later) {
+		var kind = epoll.GetAcceptorKind(accept)
+		var connChan = accept.GetConnChan()
+		var closeChan = my.wc.C()
+		for {
+			select {
+			case conn := <-connChan:
+				select {
+				case my.connChan <- acceptedConn{kind: kind, conn: conn}:
+				case <-closeChan:
+					return
+				}
+			case <-closeChan:
+				return
+			}
+		}
+	})
+}
+
+// Close 关闭App: 停止接收新链接, 取消所有handler的ctx, 并关闭所有session. 如果App已经Start()了, 还会调用component的生命周期方法
+func (my *App) Close() error {
+	return my.wc.Close(func() error {
+		var components = my.takeStartedComponents()
+		beforeShutdownComponents(components)
+		if len(components) > 0 {
+			my.waitSendingQueues(my.shutdownTimeout)
+		}
+
+		my.cancel()
+		my.sessions.Range(func(key interface{}, value interface{}) {
+			if session, ok := value.(Session); ok {
+				_ = session.(*sessionWrapper).Close()
+			}
+		})
+
+		shutdownComponents(components)
+		return nil
+	})
+}
+
 func (my *App) goLoop(later loom.Later) {
 	var fetus = &appFetus{}
 
 	var closeChan = my.wc.C()
 	for {
 		select {
-		case conn := <-my.accept.GetConnChan():
-			my.onNewSession(fetus, conn)
+		case item := <-my.connChan:
+			my.onNewSession(fetus, item.kind, item.conn)
 		case task := <-my.tasks.C:
 			var err = task.Do(fetus)
 			if err != nil {
@@ -120,14 +219,15 @@ func (my *App) goLoop(later loom.Later) {
 	}
 }
 
-func (my *App) onNewSession(fetus *appFetus, conn epoll.PlayerConn) {
-	var session = NewSession(my, conn)
+func (my *App) onNewSession(fetus *appFetus, kind string, conn epoll.PlayerConn) {
+	var session = NewSession(my, kind, conn)
 
 	var id = session.Id()
 	my.sessions.Put(id, session)
 
 	session.OnClosed(func() {
 		my.sessions.Remove(id)
+		my.gateAllowlist.Remove(id)
 	})
 
 	// for循环中小心closure的问题
@@ -155,26 +255,154 @@ func (my *App) OnHandShaken(handler func(session Session)) {
 
 func (my *App) Register(comp component.Component, opts ...component.Option) error {
 	s := component.NewService(comp, opts)
+	extracted, err := my.extractHandler(s)
+	if err != nil {
+		return err
+	}
 
-	if _, ok := my.services[s.Name]; ok {
-		return fmt.Errorf("handler: service already defined: %s", s.Name)
+	if _, _, err := my.updateService("", s, extracted); err != nil {
+		return err
 	}
 
-	if err := s.ExtractHandler(); err != nil {
+	my.swapComponent(nil, s)
+	return nil
+}
+
+// Unregister 在运行时移除一个service, 比如通过调试命令关闭出问题的功能. 已经在执行中的handler不受影响,
+// 之后再调用这个service的request会收到ErrRouteNotFound
+func (my *App) Unregister(name string) error {
+	old, last, err := my.updateService(name, nil, nil)
+	if err != nil {
 		return err
 	}
 
-	// register all handlers
-	my.services[s.Name] = s
-	for name, handler := range s.Handlers {
-		var route1 = fmt.Sprintf("%s.%s", s.Name, name)
-		my.handlers[route1] = handler
-		logo.Debug("route=%s", route1)
+	my.retireService(old, last)
+	my.swapComponent(old, nil)
+	return nil
+}
+
+// Replace 在运行时用comp替换同名的service, 新来的消息会直接使用新的handler, 已经在执行中的handler不受影响.
+// 旧component的Shutdown()在它正在执行的handler都返回之后才调用
+func (my *App) Replace(comp component.Component, opts ...component.Option) error {
+	s := component.NewService(comp, opts)
+	extracted, err := my.extractHandler(s)
+	if err != nil {
+		return err
+	}
+
+	old, last, err := my.updateService(s.Name, s, extracted)
+	if err != nil {
+		return err
 	}
 
+	my.retireService(old, last)
+	my.swapComponent(old, s)
 	return nil
 }
 
+// extractHandler 严格模式下的*component.ExtractError先不返回, 由updateService()与route冲突合并为一个错误.
+// 开启WithArgValidation()时同时编译参数的validate tag, tag写错时注册失败
+func (my *App) extractHandler(s *component.Service) (*component.ExtractError, error) {
+	var err = s.ExtractHandler()
+	var extracted, ok = err.(*component.ExtractError)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	if !my.argValidation {
+		return extracted, nil
+	}
+
+	for name, handler := range s.Handlers {
+		if err := validate.Compile(handler.Type); err != nil {
+			var err1 = fmt.Errorf("handler %s.%s: %s", s.Name, name, err.Error())
+			if !s.IsStrict() {
+				return nil, err1
+			}
+
+			if extracted == nil {
+				extracted = &component.ExtractError{Service: s.Name}
+			}
+			extracted.Errors = append(extracted.Errors, err1)
+		}
+	}
+
+	return extracted, nil
+}
+
+// updateService 以copy-on-write的方式修改table: oldName不为空时移除旧的service, s不为nil时添加新的service.
+// 严格模式下extracted中的问题与route冲突一起返回, 非严格模式下覆盖已有的route时打印警告日志. 同时返回修改之前的table
+func (my *App) updateService(oldName string, s *component.Service, extracted *component.ExtractError) (*component.Service, *handlerTable, error) {
+	my.tableLock.Lock()
+	defer my.tableLock.Unlock()
+
+	var table = my.loadTable()
+	var old *component.Service
+	if oldName != "" {
+		if old = table.services[oldName]; old == nil {
+			return nil, nil, fmt.Errorf("handler: service not found: %s", oldName)
+		}
+	} else if _, ok := table.services[s.Name]; ok {
+		return nil, nil, fmt.Errorf("handler: service already defined: %s", s.Name)
+	}
+
+	var next = table.clone()
+	if old != nil {
+		next.removeService(old)
+	}
+
+	if s != nil {
+		var collisions = next.checkCollisions(s)
+		if s.IsStrict() {
+			var errs = collisions
+			if extracted != nil {
+				errs = append(extracted.Errors, collisions...)
+			}
+
+			if len(errs) > 0 {
+				return nil, nil, &component.ExtractError{Service: s.Name, Errors: errs}
+			}
+		} else {
+			for _, err := range collisions {
+				logo.Warn("service=%s overrides an existing handler: %s", s.Name, err.Error())
+			}
+		}
+
+		next.addService(s, newServiceDispatcher(s, my.wc.C()))
+		for name := range s.Handlers {
+			logo.Debug("route=%s.%s", s.Name, name)
+		}
+	}
+
+	my.table.Store(next)
+	return old, table, nil
+}
+
+// retireService 旧service的worker在处理完队列中剩余的消息之后退出, 等正在执行的handler都返回之后, 才能调用Shutdown()
+func (my *App) retireService(old *component.Service, last *handlerTable) {
+	if dispatcher := last.dispatchers[old.Name]; dispatcher != nil {
+		_ = dispatcher.Close()
+	}
+
+	if !last.counters[old.Name].retire(my.shutdownTimeout) {
+		logo.Warn("service=%s still has running handlers after waiting %s", old.Name, my.shutdownTimeout)
+	}
+
+	logo.Info("service=%s is unregistered", old.Name)
+}
+
+// acquireTable 返回当前的table, 并把请求计入service正在执行的handler数, 处理完之后需要调用counter.leave()
+func (my *App) acquireTable(service string) (*handlerTable, *handlerCounter) {
+	for {
+		var table = my.loadTable()
+		var counter = table.counters[service]
+		// service刚刚被Unregister()或Replace(), 重新读取table
+		if counter.enter() {
+			return table, counter
+		}
+	}
+}
+
 func (my *App) AddHook(callback HookFunc) {
 	var last = my.hookCallback
 	my.hookCallback = func(rawMethod func() (interface{}, error)) (i interface{}, e error) {
@@ -184,19 +412,26 @@ func (my *App) AddHook(callback HookFunc) {
 	}
 }
 
+func (my *App) loadTable() *handlerTable {
+	return my.table.Load().(*handlerTable)
+}
+
 func (my *App) getHandler(rt *route.Route) (*component.Handler, error) {
-	handler, ok := my.handlers[rt.Short()]
-	if !ok {
-		e := fmt.Errorf("handler: %s not found", rt.String())
-		return nil, e
+	return my.loadTable().getHandler(rt)
+}
+
+// getHandlerTimeout route单独设置的超时时间优先于默认的超时时间
+func (my *App) getHandlerTimeout(rt *route.Route) time.Duration {
+	if timeout, ok := my.routeTimeouts[rt.Short()]; ok {
+		return timeout
 	}
 
-	return handler, nil
+	return my.handlerTimeout
 }
 
 // Documentation returns handler and remotes documentacion
 func (my *App) Documentation(getPtrNames bool) (map[string]interface{}, error) {
-	handlerDocs, err := docgenerator.HandlersDocs("game", my.services, getPtrNames)
+	handlerDocs, err := docgenerator.HandlersDocs("game", my.loadTable().services, getPtrNames)
 	if err != nil {
 		return nil, err
 	}
@@ -256,7 +491,7 @@ func (my *App) getSender(sessionId int64) *sessionSender {
 func createSenders(options appOptions) []*sessionSender {
 	var senders = make([]*sessionSender, options.SenderCount)
 	for i := 0; i < options.SenderCount; i++ {
-		senders[i] = newSessionSender(options.SenderBufferSize)
+		senders[i] = newSessionSender(options)
 	}
 
 	return senders
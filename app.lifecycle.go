@@ -0,0 +1,153 @@
+package road
+
+import (
+	"github.com/lixianmin/got/loom"
+	"github.com/lixianmin/logo"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/epoll"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+component可以选择实现component.Initializer等生命周期接口:
+1. Start()时按注册顺序调用所有的Init(), 然后再按注册顺序调用所有的AfterInit()
+2. Start()之后才Register()的component, 会在Register()中立即调用Init()与AfterInit()
+3. Start()之后被Unregister()或Replace()掉的component, 会立即调用BeforeShutdown()与Shutdown()
+4. Close()时先停止接收新链接, 按注册的逆序调用BeforeShutdown(), 等待session的发送队列清空(有超时), 关闭所有session之后,
+   再按注册的逆序调用Shutdown()
+
+生命周期方法中可以调用Register(), 因此调用时不持有锁
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// Start 初始化所有已经注册的component, 重复调用无效
+func (my *App) Start() {
+	my.lifecycleLock.Lock()
+	if my.started {
+		my.lifecycleLock.Unlock()
+		return
+	}
+
+	my.started = true
+	var list = append([]*component.Service(nil), my.components...)
+	my.lifecycleLock.Unlock()
+
+	initComponents(list)
+}
+
+// swapComponent 按注册顺序记录component: old为nil时追加s, s为nil时移除old, 否则s替换old原来的位置.
+// 如果App已经Start()了, 则立即初始化s, 并关闭old
+func (my *App) swapComponent(old *component.Service, s *component.Service) {
+	my.lifecycleLock.Lock()
+	var index = -1
+	for i, item := range my.components {
+		if item == old {
+			index = i
+			break
+		}
+	}
+
+	switch {
+	case index < 0 && s != nil:
+		my.components = append(my.components, s)
+	case index >= 0 && s != nil:
+		my.components[index] = s
+	case index >= 0:
+		my.components = append(my.components[:index:index], my.components[index+1:]...)
+	}
+
+	var started = my.started
+	my.lifecycleLock.Unlock()
+
+	if started {
+		if s != nil {
+			initComponents([]*component.Service{s})
+		}
+
+		if old != nil {
+			var list = []*component.Service{old}
+			beforeShutdownComponents(list)
+			shutdownComponents(list)
+		}
+	}
+}
+
+// waitSendingQueues 等待所有session的发送队列与链接的写缓冲清空, 让BeforeShutdown()中的推送有机会发给client, 最多等待timeout
+func (my *App) waitSendingQueues(timeout time.Duration) {
+	var deadline = time.Now().Add(timeout)
+	for {
+		var pending = 0
+		my.sessions.Range(func(key interface{}, value interface{}) {
+			if session, ok := value.(*sessionWrapper); ok && (!session.queue.isEmpty() || epoll.GetPendingWriteBytes(session.conn) > 0) {
+				pending++
+			}
+		})
+
+		if pending == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			logo.Warn("%d sessions still have pending data after waiting %s", pending, timeout)
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// takeStartedComponents 返回需要关闭的component, 没有Start()过的component不需要关闭
+func (my *App) takeStartedComponents() []*component.Service {
+	my.lifecycleLock.Lock()
+	defer my.lifecycleLock.Unlock()
+
+	if !my.started {
+		return nil
+	}
+
+	my.started = false
+	return append([]*component.Service(nil), my.components...)
+}
+
+func initComponents(list []*component.Service) {
+	for _, s := range list {
+		if comp, ok := s.Receiver.Interface().(component.Initializer); ok {
+			callLifecycle(s.Name, "Init", comp.Init)
+		}
+	}
+
+	for _, s := range list {
+		if comp, ok := s.Receiver.Interface().(component.AfterInitializer); ok {
+			callLifecycle(s.Name, "AfterInit", comp.AfterInit)
+		}
+	}
+}
+
+func beforeShutdownComponents(list []*component.Service) {
+	for i := len(list) - 1; i >= 0; i-- {
+		var s = list[i]
+		if comp, ok := s.Receiver.Interface().(component.BeforeShutdowner); ok {
+			callLifecycle(s.Name, "BeforeShutdown", comp.BeforeShutdown)
+		}
+	}
+}
+
+func shutdownComponents(list []*component.Service) {
+	for i := len(list) - 1; i >= 0; i-- {
+		var s = list[i]
+		if comp, ok := s.Receiver.Interface().(component.Shutdowner); ok {
+			callLifecycle(s.Name, "Shutdown", comp.Shutdown)
+		}
+	}
+}
+
+// callLifecycle 一个component的panic不应该影响其它component的生命周期
+func callLifecycle(serviceName string, methodName string, method func()) {
+	defer loom.DumpIfPanic()
+	logo.Debug("service=%s, lifecycle=%s", serviceName, methodName)
+	method()
+}
@@ -0,0 +1,70 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/message"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// MemoryFarewell 在BeforeShutdown()中给所有session推送最后的消息
+type MemoryFarewell struct {
+	sessions chan Session
+}
+
+func (my *MemoryFarewell) BeforeShutdown() {
+	for {
+		select {
+		case session := <-my.sessions:
+			_ = session.Push("farewell.onBye", &MemoryEnterResponse{Greeting: "bye"})
+		default:
+			return
+		}
+	}
+}
+
+func (my *MemoryFarewell) Chat(ctx context.Context, request *MemoryEnterRequest) {
+}
+
+func TestBeforeShutdownPushDelivered(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var farewell = &MemoryFarewell{sessions: make(chan Session, 1)}
+	if err := app.Register(farewell, component.WithName("farewell")); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+	app.Start()
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	// client读完handshake之后才开始接收消息
+	farewell.sessions <- <-sessionChan
+	var closed = make(chan struct{})
+	go func() {
+		_ = app.Close()
+		close(closed)
+	}()
+
+	var msg = receiveMessage(t, c)
+	if msg.Type != message.Push || msg.Route != "farewell.onBye" {
+		t.Fatalf("unexpected message: type=%v route=%q", msg.Type, msg.Route)
+	}
+
+	<-closed
+}
@@ -12,11 +12,24 @@ Copyright (C) - All Rights Reserved
 *********************************************************************/
 
 type appOptions struct {
-	HeartbeatInterval        time.Duration // 心跳间隔
-	DataCompression          bool          // 数据是否压缩
-	SenderBufferSize         int           // sender的发送缓冲区大小
-	SenderCount              int           // sender的数量
-	SessionRateLimitBySecond int           // session每秒限流
+	HeartbeatInterval        time.Duration            // 心跳间隔
+	DataCompression          bool                     // 数据是否压缩
+	SenderBufferSize         int                      // 已废弃: 每个session有独立的发送队列, 参考SessionQueueMaxMessages
+	SenderCount              int                      // sender的数量
+	SenderMaxBatchSize       int                      // sender每一轮最多合并写入的字节数
+	SenderMaxDelay           time.Duration            // sender收到数据后最多等待多久再合并写入, 为0时不等待
+	SessionRateLimitBySecond int                      // session每秒限流
+	SessionQueueMaxMessages  int                      // session发送队列最多积压的消息数, 为0时不限制
+	SessionQueueMaxBytes     int                      // session发送队列最多积压的字节数, 为0时不限制
+	SessionQueuePolicy       OverflowPolicy           // session发送队列超出上限时的策略
+	SessionTaskQueueSize     int                      // session任务队列的长度, 为0时不开启Post()
+	SessionTaskBudget        time.Duration            // session任务执行超过这个时间时打印警告日志
+	HandlerTimeout           time.Duration            // handler的默认超时时间, 为0时不限制
+	RouteTimeouts            map[string]time.Duration // 按route设置的handler超时时间, 比如"Room.Enter"
+	ArgValidation            bool                     // 是否根据validate tag校验handler的参数
+	ErrorPolicy              ErrorPolicy              // handler返回的错误如何发给client
+	NodeId                   string                   // RequestId的前缀, 用于区分不同的进程
+	ShutdownTimeout          time.Duration            // Close()时等待session发送队列清空, 以及移除service时等待handler返回的最长时间
 }
 
 type AppOption func(*appOptions)
@@ -35,6 +48,7 @@ func WithDataCompression(compression bool) AppOption {
 	}
 }
 
+// WithSenderBufferSize Deprecated: 每个session有独立的发送队列, 请使用WithSessionQueueLimit()
 func WithSenderBufferSize(size int) AppOption {
 	return func(options *appOptions) {
 		if size > 0 {
@@ -51,6 +65,25 @@ func WithSenderCount(count int) AppOption {
 	}
 }
 
+// WithSenderMaxBatchSize sender每一轮最多合并size字节, 同一个session的多个packet会合并为一次Write()
+func WithSenderMaxBatchSize(size int) AppOption {
+	return func(options *appOptions) {
+		if size > 0 {
+			options.SenderMaxBatchSize = size
+		}
+	}
+}
+
+// WithSenderMaxDelay sender收到第一条数据后最多等待delay再写, 用一点延迟换取更少的系统调用与websocket帧.
+// 默认为0, 只合并已经排队的数据, 不增加延迟
+func WithSenderMaxDelay(delay time.Duration) AppOption {
+	return func(options *appOptions) {
+		if delay >= 0 {
+			options.SenderMaxDelay = delay
+		}
+	}
+}
+
 func WithSessionRateLimitBySecond(limit int) AppOption {
 	return func(options *appOptions) {
 		if limit > 0 {
@@ -58,3 +91,101 @@ func WithSessionRateLimitBySecond(limit int) AppOption {
 		}
 	}
 }
+
+// WithSessionQueueLimit 每个session的发送队列最多积压maxMessages条消息与maxBytes字节, 为0表示不限制该项.
+// 超出上限时按WithSessionQueuePolicy()处理, 默认断开慢消费者
+func WithSessionQueueLimit(maxMessages int, maxBytes int) AppOption {
+	return func(options *appOptions) {
+		if maxMessages >= 0 {
+			options.SessionQueueMaxMessages = maxMessages
+		}
+
+		if maxBytes >= 0 {
+			options.SessionQueueMaxBytes = maxBytes
+		}
+	}
+}
+
+// WithSessionQueuePolicy session发送队列超出上限时的策略: 断开链接, 丢弃最早的数据, 或者丢弃新数据并让Push()返回ErrSendingQueueFull
+func WithSessionQueuePolicy(policy OverflowPolicy) AppOption {
+	return func(options *appOptions) {
+		if policy >= OverflowDisconnect && policy <= OverflowDropNewest {
+			options.SessionQueuePolicy = policy
+		}
+	}
+}
+
+// WithSessionTaskQueue 开启session的任务队列, 最多积压size个任务. 开启后可以通过Session.Post()把定时器, 匹配结果,
+// 数据库回调等逻辑投递到session的goroutine中执行, 与handler串行, 不再需要为玩家数据加锁
+func WithSessionTaskQueue(size int) AppOption {
+	return func(options *appOptions) {
+		if size >= 0 {
+			options.SessionTaskQueueSize = size
+		}
+	}
+}
+
+// WithSessionTaskBudget session的任务执行超过budget时打印警告日志, 因为任务会卡住同一个session的心跳与网络消息
+func WithSessionTaskBudget(budget time.Duration) AppOption {
+	return func(options *appOptions) {
+		if budget > 0 {
+			options.SessionTaskBudget = budget
+		}
+	}
+}
+
+// WithHandlerTimeout handler的默认超时时间. 超时后handler的ctx会被取消, client立即收到ErrHandlerTimeout,
+// handler之后返回的结果会被丢弃
+func WithHandlerTimeout(timeout time.Duration) AppOption {
+	return func(options *appOptions) {
+		if timeout >= 0 {
+			options.HandlerTimeout = timeout
+		}
+	}
+}
+
+// WithArgValidation 开启之后, handler的参数在反序列化之后会根据validate tag校验(规则见validate包),
+// 校验失败时client收到ErrInvalidArgument, 其中details是每个字段的错误, handler不会被调用. tag写错时Register()与Handle()返回错误
+func WithArgValidation(enable bool) AppOption {
+	return func(options *appOptions) {
+		options.ArgValidation = enable
+	}
+}
+
+// WithErrorPolicy 生产环境可以使用ErrorPolicyHide, 避免把内部的错误信息(比如数据库的报错)发给client,
+// 此时只有code通过RegisterErrorCode()注册过的*Error才会原样发给client
+func WithErrorPolicy(policy ErrorPolicy) AppOption {
+	return func(options *appOptions) {
+		if policy == ErrorPolicyExpose || policy == ErrorPolicyHide {
+			options.ErrorPolicy = policy
+		}
+	}
+}
+
+// WithRouteTimeout 为某一个route(比如"Room.Enter")单独设置超时时间, 优先于WithHandlerTimeout(), 设置为0表示这个route不限制
+func WithRouteTimeout(route string, timeout time.Duration) AppOption {
+	return func(options *appOptions) {
+		if route != "" && timeout >= 0 {
+			options.RouteTimeouts[route] = timeout
+		}
+	}
+}
+
+// WithNodeId 设置RequestId的前缀, 比如机器名或者pod名, 方便在多个进程的日志中查找同一个请求. 默认是进程启动时随机生成的
+func WithNodeId(id string) AppOption {
+	return func(options *appOptions) {
+		if id != "" {
+			options.NodeId = id
+		}
+	}
+}
+
+// WithShutdownTimeout Close()时在BeforeShutdown()之后最多等待timeout, 让session发送队列中的数据发给client;
+// Unregister()与Replace()时最多等待timeout, 让旧service正在执行的handler返回之后再调用Shutdown(). 为0时不等待
+func WithShutdownTimeout(timeout time.Duration) AppOption {
+	return func(options *appOptions) {
+		if timeout >= 0 {
+			options.ShutdownTimeout = timeout
+		}
+	}
+}
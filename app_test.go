@@ -0,0 +1,326 @@
+package road
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/epoll"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+通过MemoryAcceptor在进程内完整地走一遍handshake, request与push, 不需要打开socket
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type MemoryEnterRequest struct {
+	Name string `json:"name"`
+}
+
+type MemoryEnterResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+type MemoryRoom struct{}
+
+func (room *MemoryRoom) Enter(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+	return &MemoryEnterResponse{Greeting: "hello " + request.Name}, nil
+}
+
+func newTestApp(t testing.TB, opts ...AppOption) (*App, *epoll.MemoryAcceptor) {
+	var accept = epoll.NewMemoryAcceptor()
+	opts = append([]AppOption{WithSessionRateLimitBySecond(1000000)}, opts...)
+	var app = NewApp(accept, opts...)
+	t.Cleanup(func() { _ = app.Close() })
+
+	if err := app.Register(&MemoryRoom{}, component.WithName("room")); err != nil {
+		t.Fatal(err)
+	}
+
+	return app, accept
+}
+
+// onHandShakenSync OnHandShaken()通过app的任务队列异步注册, 等注册生效之后再返回, 防止client连得太快而错过handler
+func onHandShakenSync(app *App, handler func(session Session)) {
+	app.OnHandShaken(handler)
+	app.tasks.SendCallback(func(args interface{}) (interface{}, error) {
+		return nil, nil
+	}).Get1()
+}
+
+func receiveMessage(t testing.TB, c *client.Client) *message.Message {
+	select {
+	case msg := <-c.MsgChannel():
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for message")
+		return nil
+	}
+}
+
+func TestMemoryRequestResponse(t *testing.T) {
+	var _, accept = newTestApp(t)
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	var id, err1 = c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`))
+	if err1 != nil {
+		t.Fatal(err1)
+	}
+
+	var msg = receiveMessage(t, c)
+	if msg.Type != message.Response || msg.Id != id || msg.Err {
+		t.Fatalf("unexpected message: type=%v id=%d err=%v", msg.Type, msg.Id, msg.Err)
+	}
+
+	var response MemoryEnterResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Greeting != "hello kitty" {
+		t.Fatalf("greeting=%q", response.Greeting)
+	}
+}
+
+// TestMemoryPushWithoutReading client端不读push时, 不应该阻塞共用同一个sender的其它session
+func TestMemoryPushWithoutReading(t *testing.T) {
+	var app, accept = newTestApp(t, WithSenderCount(1))
+	var pushed = make(chan struct{})
+	var once sync.Once
+	onHandShakenSync(app, func(session Session) {
+		once.Do(func() {
+			// 超过sender单次合并写入的上限, 从而需要多次Write()
+			var greeting = strings.Repeat("x", 1024)
+			for i := 0; i < 500; i++ {
+				_ = session.Push("room.onChat", &MemoryEnterResponse{Greeting: greeting})
+			}
+
+			close(pushed)
+		})
+	})
+
+	var idle, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Disconnect()
+	<-pushed
+
+	var c, err1 = client.NewMemoryClient(accept)
+	if err1 != nil {
+		t.Fatal(err1)
+	}
+	defer c.Disconnect()
+
+	_, _ = c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`))
+	if msg := receiveMessage(t, c); msg.Type != message.Response || msg.Err {
+		t.Fatalf("unexpected message: type=%v err=%v", msg.Type, msg.Err)
+	}
+}
+
+func TestMemoryPushAfterClose(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	var session = <-sessionChan
+	_ = session.(*sessionWrapper).Close()
+	if err := session.Push("room.onChat", &MemoryEnterResponse{}); err != ErrSessionClosed {
+		t.Fatalf("expect ErrSessionClosed, got err=%v", err)
+	}
+}
+
+// TestMemorySenderMaxDelay 开启合并延迟时, 推送仍然按顺序到达
+func TestMemorySenderMaxDelay(t *testing.T) {
+	var app, accept = newTestApp(t, WithSenderCount(1), WithSenderMaxDelay(5*time.Millisecond))
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	// client在读完handshake之后才开始接收消息, 因此等NewMemoryClient()返回之后再推送
+	const count = 10
+	var session = <-sessionChan
+	for i := 0; i < count; i++ {
+		_ = session.Push("room.onChat", &MemoryEnterResponse{Greeting: strconv.Itoa(i)})
+	}
+
+	for i := 0; i < count; i++ {
+		var msg = receiveMessage(t, c)
+		var response MemoryEnterResponse
+		if err := json.Unmarshal(msg.Data, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.Type != message.Push || response.Greeting != strconv.Itoa(i) {
+			t.Fatalf("unexpected push %d: type=%v greeting=%q", i, msg.Type, response.Greeting)
+		}
+	}
+}
+
+// TestMemoryKick client先收到kick包, 之后读到EOF
+func TestMemoryKick(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var conn, err = accept.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var encoder = codec.NewPomeloPacketEncoder()
+	var handshake, _ = encoder.Encode(packet.Handshake, []byte(`{"sys":{"platform":"test"}}`))
+	var ack, _ = encoder.Encode(packet.HandshakeAck, nil)
+	if _, err := conn.Write(append(handshake, ack...)); err != nil {
+		t.Fatal(err)
+	}
+
+	var session = <-sessionChan
+	if err := session.Kick(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 读到EOF才会返回, 说明server在kick包之后断开了链接
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var data, err1 = io.ReadAll(conn)
+	if err1 != nil {
+		t.Fatal(err1)
+	}
+
+	var packets, _ = codec.NewPomeloPacketDecoder().Decode(data)
+	if len(packets) == 0 || packets[len(packets)-1].Type != packet.Kick {
+		t.Fatalf("expect the kick packet before EOF, got %d packets", len(packets))
+	}
+}
+
+// TestMemoryRateLimit 令牌用完之后先回复ErrTriggerRateLimit, 继续发送则被断开
+func TestMemoryRateLimit(t *testing.T) {
+	var app, accept = newTestApp(t, WithSessionRateLimitBySecond(1))
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	var session = <-sessionChan
+	var responses, limited = 0, 0
+	for i := 0; i < 30 && c.IsConnected(); i++ {
+		if _, err := c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`)); err != nil {
+			break
+		}
+
+		select {
+		case msg := <-c.MsgChannel():
+			switch {
+			case !msg.Err:
+				if limited > 0 {
+					t.Fatal("no response is expected after the rate limit is triggered")
+				}
+				responses++
+			case strings.Contains(string(msg.Data), ErrTriggerRateLimit.Code):
+				limited++
+			default:
+				t.Fatalf("unexpected error: %s", msg.Data)
+			}
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	if responses == 0 || limited == 0 {
+		t.Fatalf("expect responses and rate limit errors, got responses=%d limited=%d", responses, limited)
+	}
+
+	select {
+	case <-session.(*sessionWrapper).wc.C():
+	case <-time.After(3 * time.Second):
+		t.Fatal("session should be closed by the rate limit")
+	}
+
+	if reason := session.CloseReason(); reason != ErrKickedByRateLimit {
+		t.Fatalf("expect ErrKickedByRateLimit, got %v", reason)
+	}
+}
+
+// TestMemoryDroppedResponse 丢弃策略下回复被丢弃时只丢掉这一条回复, session继续工作
+func TestMemoryDroppedResponse(t *testing.T) {
+	var app, accept = newTestApp(t, WithSessionQueueLimit(1, 0), WithSessionQueuePolicy(OverflowDropNewest),
+		WithSenderCount(1), WithSenderMaxDelay(200*time.Millisecond))
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	// push在sender的延迟期间占满队列, 紧接着的回复会被丢弃
+	var session = <-sessionChan
+	if err := session.Push("room.onChat", &MemoryEnterResponse{Greeting: "push"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.SendRequest("room.Enter", []byte(`{"name":"dropped"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := receiveMessage(t, c); msg.Type != message.Push {
+		t.Fatalf("expect the push, got type=%v", msg.Type)
+	}
+
+	var id, err1 = c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`))
+	if err1 != nil {
+		t.Fatal(err1)
+	}
+
+	var msg = receiveMessage(t, c)
+	if msg.Type != message.Response || msg.Id != id || msg.Err {
+		t.Fatalf("expect the response of the second request, got type=%v id=%d err=%v", msg.Type, msg.Id, msg.Err)
+	}
+
+	if !c.IsConnected() || session.CloseReason() != nil {
+		t.Fatalf("session should survive a dropped response, reason=%v", session.CloseReason())
+	}
+}
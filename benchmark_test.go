@@ -0,0 +1,135 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/util/bufferpool"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+编码, 推送与接收路径的benchmark, 重点关注每次操作的分配次数:
+
+	go test -run=^$ -bench=. -benchmem
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type MemoryCounter struct {
+	count  int64
+	target int64
+	done   chan struct{}
+}
+
+func (counter *MemoryCounter) Chat(ctx context.Context, request *MemoryEnterRequest) {
+	if atomic.AddInt64(&counter.count, 1) == counter.target {
+		counter.done <- struct{}{}
+	}
+}
+
+// dialBenchmarkSession 用裸的net.Conn完成握手, 之后丢弃所有下行数据, 这样client端不会产生分配
+func dialBenchmarkSession(b *testing.B, opts ...AppOption) (*sessionImpl, net.Conn) {
+	var app, accept = newTestApp(b, opts...)
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var conn, err = accept.Dial()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}()
+
+	var encoder = codec.NewPomeloPacketEncoder()
+	var handshake, _ = encoder.Encode(packet.Handshake, []byte(`{"sys":{"platform":"benchmark"}}`))
+	var ack, _ = encoder.Encode(packet.HandshakeAck, nil)
+	if _, err := conn.Write(append(handshake, ack...)); err != nil {
+		b.Fatal(err)
+	}
+
+	var session = <-sessionChan
+	return session.(*sessionWrapper).sessionImpl, conn
+}
+
+func BenchmarkEncodeMessage(b *testing.B) {
+	var session, _ = dialBenchmarkSession(b)
+	var msg = &message.Message{Type: message.Push, Route: "room.onChat", Data: []byte(`{"greeting":"hello kitty"}`)}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var data, err = session.packetEncodeMessage(msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			bufferpool.Put(data)
+		}
+	})
+
+	// 引入bufferpool之前的路径: message与packet分别编码, 各自分配一次内存, 作为pooled的对照
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var data, err = session.app.messageEncoder.Encode(msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if _, err = session.app.packetEncoder.Encode(packet.Data, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPush(b *testing.B) {
+	var session, _ = dialBenchmarkSession(b, WithSessionQueueLimit(0, 0))
+	var response = &MemoryEnterResponse{Greeting: "hello kitty"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := session.Push("room.onChat", response); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReceive(b *testing.B) {
+	var counter = &MemoryCounter{target: int64(b.N), done: make(chan struct{}, 1)}
+	var session, conn = dialBenchmarkSession(b)
+	if err := session.app.Register(counter, component.WithName("counter")); err != nil {
+		b.Fatal(err)
+	}
+
+	var data, _ = message.NewMessagesEncoder(false).Encode(&message.Message{Type: message.Notify, Route: "counter.Chat", Data: []byte(`{"name":"kitty"}`)})
+	var notify, _ = codec.NewPomeloPacketEncoder().Encode(packet.Data, data)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := conn.Write(notify); err != nil {
+				return
+			}
+		}
+	}()
+
+	<-counter.done
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/lixianmin/road/conn/codec"
 	"github.com/lixianmin/road/conn/message"
 	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/epoll"
 	"github.com/lixianmin/road/util/compression"
 	"net"
 	"net/url"
@@ -213,7 +214,8 @@ func (c *Client) readPackets(buf *bytes.Buffer) ([]*packet.Packet, error) {
 		}
 		buf.Write(data[:n])
 	}
-	packets, err := c.packetDecoder.Decode(buf.Bytes())
+	// Decode()返回的packet直接引用传入的内存, 而buf会被后续的读取覆盖, packet又会交给其它goroutine处理, 所以先copy一份
+	packets, err := c.packetDecoder.Decode(append([]byte(nil), buf.Bytes()...))
 	if err != nil {
 		logo.Info("error decoding packet from server: %s", err.Error())
 	}
@@ -324,6 +326,33 @@ func (c *Client) ConnectToWS(addr string, path string, tlsConfig ...*tls.Config)
 	return nil
 }
 
+// NewMemoryClient returns a new client connected to the in-memory acceptor, the handshake is already done when it returns
+func NewMemoryClient(accept *epoll.MemoryAcceptor, requestTimeout ...time.Duration) (*Client, error) {
+	var c = New(requestTimeout...)
+	if err := c.ConnectToMemory(accept); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ConnectToMemory connects to the in-memory acceptor without opening any socket, it is used by unit tests
+func (c *Client) ConnectToMemory(accept *epoll.MemoryAcceptor) error {
+	conn, err := accept.Dial()
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.IncomingMsgChan = make(chan *message.Message, 10)
+
+	if err = c.handleHandshake(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) handleHandshake() error {
 	if err := c.sendHandshakeRequest(); err != nil {
 		return err
@@ -9,3 +9,38 @@ Copyright (C) - All Rights Reserved
 
 type Component interface {
 }
+
+// 下面是component可选实现的生命周期接口, App在Start()时按注册顺序调用Init()与AfterInit(),
+// 在Close()时按注册的逆序调用BeforeShutdown()与Shutdown()
+type (
+	// Initializer 加载配置等初始化工作, 此时其它component的Init()不一定已经执行
+	Initializer interface {
+		Init()
+	}
+
+	// AfterInitializer 所有component的Init()都执行完成之后调用, 可以用来预热缓存或者访问其它component
+	AfterInitializer interface {
+		AfterInit()
+	}
+
+	// BeforeShutdowner App已经停止接收新链接, 但session还没有关闭, 可以在这里给client推送最后的消息.
+	// App会等待session的发送队列清空之后再关闭session, 最多等待WithShutdownTimeout()设置的时间
+	BeforeShutdowner interface {
+		BeforeShutdown()
+	}
+
+	// Shutdowner 所有session都已经关闭, 可以在这里落地数据, 释放资源
+	Shutdowner interface {
+		Shutdown()
+	}
+)
+
+// isLifecycleMethod 生命周期方法不是handler, 严格模式下不应该报告为被跳过的方法
+func isLifecycleMethod(name string) bool {
+	switch name {
+	case "Init", "AfterInit", "BeforeShutdown", "Shutdown":
+		return true
+	default:
+		return false
+	}
+}
@@ -2,6 +2,8 @@ package component
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/lixianmin/road/conn/message"
 	"reflect"
 	"unicode"
@@ -28,35 +30,79 @@ func isExported(name string) bool {
 
 // isHandlerMethod decide a method is suitable handler method
 func isHandlerMethod(method reflect.Method) bool {
+	return checkHandlerMethod(method) == nil
+}
+
+// checkHandlerMethod 返回方法不能作为handler的原因, 严格模式下用于提示用户
+func checkHandlerMethod(method reflect.Method) error {
 	mt := method.Type
 	// Method must be exported.
 	if method.PkgPath != "" {
-		return false
+		return errors.New("method is not exported")
 	}
 
 	// Method needs two or three ins: receiver, context.Context and optional []byte or pointer.
 	if mt.NumIn() != 2 && mt.NumIn() != 3 {
-		return false
+		return fmt.Errorf("method needs 1 or 2 arguments, but has %d", mt.NumIn()-1)
 	}
 
 	if t1 := mt.In(1); !t1.Implements(typeOfContext) {
-		return false
+		return fmt.Errorf("the first argument must be context.Context, but is %s", t1)
 	}
 
 	if mt.NumIn() == 3 && mt.In(2).Kind() != reflect.Ptr && mt.In(2) != typeOfBytes {
-		return false
+		return fmt.Errorf("the second argument must be a pointer or []byte, but is %s", mt.In(2))
 	}
 
 	// Method needs either no out or two outs: interface{}(or []byte), error
 	if mt.NumOut() != 0 && mt.NumOut() != 2 {
-		return false
+		return fmt.Errorf("method needs 0 or 2 return values, but has %d", mt.NumOut())
+	}
+
+	if mt.NumOut() == 2 && mt.Out(1) != typeOfError {
+		return fmt.Errorf("the second return value must be error, but is %s", mt.Out(1))
+	}
+
+	if mt.NumOut() == 2 && mt.Out(0) != typeOfBytes && mt.Out(0).Kind() != reflect.Ptr {
+		return fmt.Errorf("the first return value must be a pointer or []byte, but is %s", mt.Out(0))
 	}
 
-	if mt.NumOut() == 2 && (mt.Out(1) != typeOfError || mt.Out(0) != typeOfBytes && mt.Out(0).Kind() != reflect.Ptr) {
-		return false
+	return nil
+}
+
+// checkHandlerMethods 严格模式下找出所有被跳过的导出方法, 以及nameFunc改名之后冲突的handler名
+func checkHandlerMethods(typ reflect.Type, nameFunc func(string) string) []error {
+	var errs []error
+	var names = make(map[string]string)
+	for m := 0; m < typ.NumMethod(); m++ {
+		method := typ.Method(m)
+		if method.PkgPath != "" {
+			continue
+		}
+
+		if isLifecycleMethod(method.Name) && method.Type.NumIn() == 1 && method.Type.NumOut() == 0 {
+			continue
+		}
+
+		if err := checkHandlerMethod(method); err != nil {
+			errs = append(errs, fmt.Errorf("method %s is skipped: %s", method.Name, err.Error()))
+			continue
+		}
+
+		mn := method.Name
+		if nameFunc != nil {
+			mn = nameFunc(mn)
+		}
+
+		if last, ok := names[mn]; ok {
+			errs = append(errs, fmt.Errorf("methods %s and %s are both renamed to %q", last, method.Name, mn))
+			continue
+		}
+
+		names[mn] = method.Name
 	}
 
-	return true
+	return errs
 }
 
 func suitableHandlerMethods(typ reflect.Type, nameFunc func(string) string) map[string]*Handler {
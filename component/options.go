@@ -1,5 +1,9 @@
 package component
 
+import (
+	"context"
+)
+
 /********************************************************************
 created:    2020-08-29
 author:     lixianmin
@@ -7,9 +11,25 @@ author:     lixianmin
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
+// DispatchMode 一个service的handler在哪里执行
+type DispatchMode int
+
+const (
+	DispatchSequential   DispatchMode = iota // 在session的goroutine中按顺序执行, 默认模式, 保证同一个session的消息顺序
+	DispatchOrderedByKey                     // 相同key的消息按顺序执行, 不同key的消息在worker池中并发执行
+	DispatchConcurrent                       // 在有上限的worker池中并发执行, 不保证顺序
+)
+
+// DispatchKeyFunc DispatchOrderedByKey模式下计算消息的key, arg是反序列化之后的handler参数(没有参数时为nil)
+type DispatchKeyFunc func(ctx context.Context, arg interface{}) string
+
 type options struct {
-	name     string              // component name
-	nameFunc func(string) string // rename handler name
+	name         string              // component name
+	nameFunc     func(string) string // rename handler name
+	dispatchMode DispatchMode        // handler的执行模式
+	dispatchKey  DispatchKeyFunc     // DispatchOrderedByKey模式下计算key
+	workerCount  int                 // 非DispatchSequential模式下worker的数量
+	strict       bool                // 严格模式下, 被跳过的导出方法与冲突的handler名都会导致注册失败
 }
 
 type Option func(options *options)
@@ -28,3 +48,40 @@ func WithNameFunc(fn func(string) string) Option {
 		opt.nameFunc = fn
 	}
 }
+
+// WithStrict 严格模式: 签名不符合handler要求的导出方法, 以及WithNameFunc()改名之后冲突的handler名,
+// 都会汇总到一个*ExtractError中由App.Register()返回, 而不是被静默跳过
+func WithStrict() Option {
+	return func(opt *options) {
+		opt.strict = true
+	}
+}
+
+// WithDispatchSequential 在session的goroutine中按顺序执行handler, 这是默认模式
+func WithDispatchSequential() Option {
+	return func(opt *options) {
+		opt.dispatchMode = DispatchSequential
+	}
+}
+
+// WithDispatchOrderedByKey key相同的消息(比如同一个房间id)按顺序执行, 不同key的消息由workerCount个worker并发执行.
+// 慢handler不再卡住session的心跳与后续消息, 回复仍然按message id对应到request
+func WithDispatchOrderedByKey(workerCount int, key DispatchKeyFunc) Option {
+	return func(opt *options) {
+		if workerCount > 0 && key != nil {
+			opt.dispatchMode = DispatchOrderedByKey
+			opt.dispatchKey = key
+			opt.workerCount = workerCount
+		}
+	}
+}
+
+// WithDispatchConcurrent 由workerCount个worker并发执行handler, 不保证同一个session中消息的顺序, 适合互相独立的查询类请求
+func WithDispatchConcurrent(workerCount int) Option {
+	return func(opt *options) {
+		if workerCount > 0 {
+			opt.dispatchMode = DispatchConcurrent
+			opt.workerCount = workerCount
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package component
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/lixianmin/road/conn/message"
 	"reflect"
+	"strings"
 )
 
 /********************************************************************
@@ -19,8 +22,12 @@ type (
 		Receiver    reflect.Value  // receiver of method
 		Method      reflect.Method // method stub
 		Type        reflect.Type   // low-level type of method
-		IsRawArg    bool           // whether the data need to serialize
+		IsRawArg    bool           // whether the data need to serialize, the raw []byte is a copy owned by the handler
 		MessageType message.Type   // handler allowed message type (either request or notify)
+
+		// 下面两个字段由road.Handle()/road.HandleNotify()设置, 不为nil时直接调用, 不经过反射
+		NewArg func() interface{}                                              // 创建用于反序列化的参数
+		Invoke func(ctx context.Context, arg interface{}) (interface{}, error) // 调用handler
 	}
 
 	// Service implements a specific service, some of it's methods will be
@@ -34,6 +41,28 @@ type (
 	}
 )
 
+// ExtractError 严格模式下注册service时发现的所有问题
+type ExtractError struct {
+	Service string
+	Errors  []error
+}
+
+func (err *ExtractError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("service %s has %d registration errors:", err.Service, len(err.Errors)))
+	for _, item := range err.Errors {
+		sb.WriteString("\n\t")
+		sb.WriteString(item.Error())
+	}
+
+	return sb.String()
+}
+
+// IsStrict 是否开启了严格模式
+func (s *Service) IsStrict() bool {
+	return s.Options.strict
+}
+
 // NewService creates a new service
 func NewService(comp Component, opts []Option) *Service {
 	s := &Service{
@@ -60,7 +89,7 @@ func NewService(comp Component, opts []Option) *Service {
 // - exported method of exported type
 // - one or two arguments
 // - the first argument is context.Context
-// - the second argument (if it exists) is []byte or a pointer
+// - the second argument (if it exists) is []byte or a pointer, the []byte is a copy and can be kept after the handler returns
 // - zero or two outputs
 // - the first output is [] or a pointer
 // - the second output is an error
@@ -74,8 +103,21 @@ func (s *Service) ExtractHandler() error {
 		return errors.New("type " + typeName + " is not exported")
 	}
 
+	// 严格模式下先记下所有的问题, 仍然安装合法的handler, 这样App.Register()可以把route冲突一起汇总进来
+	var errs []error
+	if s.Options.strict {
+		errs = checkHandlerMethods(s.Type, s.Options.nameFunc)
+	}
+
 	// Install the methods
 	s.Handlers = suitableHandlerMethods(s.Type, s.Options.nameFunc)
+	for i := range s.Handlers {
+		s.Handlers[i].Receiver = s.Receiver
+	}
+
+	if len(errs) > 0 {
+		return &ExtractError{Service: s.Name, Errors: errs}
+	}
 
 	if len(s.Handlers) == 0 {
 		str := ""
@@ -89,9 +131,20 @@ func (s *Service) ExtractHandler() error {
 		return errors.New(str)
 	}
 
-	for i := range s.Handlers {
-		s.Handlers[i].Receiver = s.Receiver
-	}
-
 	return nil
 }
+
+// DispatchMode handler的执行模式
+func (s *Service) DispatchMode() DispatchMode {
+	return s.Options.dispatchMode
+}
+
+// DispatchKey DispatchOrderedByKey模式下计算消息的key
+func (s *Service) DispatchKey() DispatchKeyFunc {
+	return s.Options.dispatchKey
+}
+
+// WorkerCount 非DispatchSequential模式下worker的数量
+func (s *Service) WorkerCount() int {
+	return s.Options.workerCount
+}
@@ -21,7 +21,6 @@
 package codec
 
 import (
-	"bytes"
 	"github.com/lixianmin/road/conn/packet"
 )
 
@@ -33,44 +32,26 @@ func NewPomeloPacketDecoder() *PomeloPacketDecoder {
 	return &PomeloPacketDecoder{}
 }
 
-func (c *PomeloPacketDecoder) forward(buf *bytes.Buffer) (int, packet.Type, error) {
-	header := buf.Next(HeadLength)
-	return ParseHeader(header)
-}
-
-// Decode decode the bytes slice to packet.Packet(s)
+// Decode decode the bytes slice to packet.Packet(s).
+// The Data of returned packets references the memory of data without copying,
+// so data must not be modified or reused while the packets are still in use.
 func (c *PomeloPacketDecoder) Decode(data []byte) ([]*packet.Packet, error) {
-	buf := bytes.NewBuffer(nil)
-	buf.Write(data)
-
-	var (
-		packets []*packet.Packet
-		err     error
-	)
-	// check length
-	if buf.Len() < HeadLength {
-		return nil, nil
-	}
+	var packets []*packet.Packet
 
-	// first time
-	size, typ, err := c.forward(buf)
-	if err != nil {
-		return nil, err
-	}
-
-	for size <= buf.Len() {
-		p := &packet.Packet{Type: typ, Length: size, Data: buf.Next(size)}
-		packets = append(packets, p)
+	for len(data) >= HeadLength {
+		size, typ, err := ParseHeader(data[:HeadLength])
+		if err != nil {
+			return nil, err
+		}
 
-		// if no more packets, break
-		if buf.Len() < HeadLength {
+		var end = HeadLength + size
+		if len(data) < end {
 			break
 		}
 
-		size, typ, err = c.forward(buf)
-		if err != nil {
-			return nil, err
-		}
+		p := &packet.Packet{Type: typ, Length: size, Data: data[HeadLength:end:end]}
+		packets = append(packets, p)
+		data = data[end:]
 	}
 
 	return packets, nil
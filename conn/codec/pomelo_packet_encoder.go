@@ -48,11 +48,8 @@ func (e *PomeloPacketEncoder) Encode(typ packet.Type, data []byte) ([]byte, erro
 		return nil, ErrPacketSizeExcced
 	}
 
-	p := &packet.Packet{Type: typ, Length: len(data)}
-	buf := make([]byte, p.Length+HeadLength)
-	buf[0] = byte(p.Type)
-
-	copy(buf[1:HeadLength], IntToBytes(p.Length))
+	buf := make([]byte, len(data)+HeadLength)
+	WriteHeader(buf, typ, len(data))
 	copy(buf[HeadLength:], data)
 
 	return buf, nil
@@ -21,6 +21,15 @@ func ParseHeader(header []byte) (int, packet.Type, error) {
 	return size, packet.Type(typ), nil
 }
 
+// WriteHeader writes the packet header into buf[:HeadLength], which is used to
+// encode a packet in place after reserving HeadLength bytes in front of the data
+func WriteHeader(buf []byte, typ packet.Type, size int) {
+	buf[0] = byte(typ)
+	buf[1] = byte((size >> 16) & 0xFF)
+	buf[2] = byte((size >> 8) & 0xFF)
+	buf[3] = byte(size & 0xFF)
+}
+
 // BytesToInt decode packet data length byte to int(Big end)
 func BytesToInt(b []byte) int {
 	result := 0
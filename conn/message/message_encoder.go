@@ -29,6 +29,7 @@ import (
 type Encoder interface {
 	IsCompressionEnabled() bool
 	Encode(message *Message) ([]byte, error)
+	EncodeTo(buf []byte, message *Message) ([]byte, error)
 }
 
 // MessagesEncoder implements MessageEncoder interface
@@ -61,11 +62,17 @@ func (my *MessagesEncoder) IsCompressionEnabled() bool {
 // The figure above indicates that the bit does not affect the type of message.
 // See ref: https://github.com/topfreegames/pitaya/blob/master/docs/communication_protocol.md
 func (my *MessagesEncoder) Encode(message *Message) ([]byte, error) {
+	return my.EncodeTo(make([]byte, 0, EncodedSize(message)), message)
+}
+
+// EncodeTo appends the encoded message to buf and returns the extended buffer, so the caller
+// can encode into a pooled buffer with the packet header reserved in front of the message
+func (my *MessagesEncoder) EncodeTo(buf []byte, message *Message) ([]byte, error) {
 	if invalidType(message.Type) {
 		return nil, ErrWrongMessageType
 	}
 
-	buf := make([]byte, 0)
+	var start = len(buf)
 	flag := byte(message.Type) << 1
 
 	code, compressed := routes[message.Route]
@@ -112,7 +119,7 @@ func (my *MessagesEncoder) Encode(message *Message) ([]byte, error) {
 
 		if len(d) < len(message.Data) {
 			message.Data = d
-			buf[0] |= gzipMask
+			buf[start] |= gzipMask
 		}
 	}
 
@@ -120,6 +127,12 @@ func (my *MessagesEncoder) Encode(message *Message) ([]byte, error) {
 	return buf, nil
 }
 
+// EncodedSize returns the upper bound of the encoded size of message without data compression
+func EncodedSize(message *Message) int {
+	// flag + 10 bytes of variant message id + 1 byte route length + route + data
+	return 1 + 10 + 1 + len(message.Route) + len(message.Data)
+}
+
 // Decode unmarshal the bytes slice to a message
 // See ref: https://github.com/topfreegames/pitaya/blob/master/docs/communication_protocol.md
 func Decode(data []byte) (*Message, error) {
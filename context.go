@@ -0,0 +1,120 @@
+package road
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/ifs"
+	"os"
+	"strconv"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+handler与hook中通过ctx读取当前请求的元数据, 用于日志与链路追踪
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type requestInfoKey struct{}
+
+var defaultNodeId = newNodeId() // 同一个进程中的多个App共用
+
+// requestInfo 每条消息一份, 在session的goroutine中创建之后只读
+type requestInfo struct {
+	nodeId     string // 区分不同的进程, 与sessionId, sequence一起组成RequestId
+	sessionId  int64
+	sequence   uint64 // session内收到的第几条消息, 与sessionId一起组成RequestId
+	messageId  uint
+	route      string
+	msgType    message.Type
+	receivedAt time.Time
+}
+
+// newNodeId 随机生成进程的nodeId, 重启之后也不会与之前的RequestId重复
+func newNodeId() string {
+	var buff [4]byte
+	if _, err := rand.Read(buff[:]); err != nil {
+		return strconv.Itoa(os.Getpid())
+	}
+
+	return hex.EncodeToString(buff[:])
+}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func getRequestInfo(ctx context.Context) (*requestInfo, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	var info, ok = ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info, ok
+}
+
+// SessionFromContext 返回处理当前消息的session, ctx不是由road创建时返回false
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	var session, ok = ctx.Value(ifs.CtxKeySession).(*sessionWrapper)
+	if !ok {
+		return nil, false
+	}
+
+	return session, true
+}
+
+// MessageIdFromContext request的message id, client用它对应回复; notify的message id为0
+func MessageIdFromContext(ctx context.Context) (uint, bool) {
+	if info, ok := getRequestInfo(ctx); ok {
+		return info.messageId, true
+	}
+
+	return 0, false
+}
+
+// RouteFromContext 消息的route, 比如"Room.Enter"
+func RouteFromContext(ctx context.Context) (string, bool) {
+	if info, ok := getRequestInfo(ctx); ok {
+		return info.route, true
+	}
+
+	return "", false
+}
+
+// MessageTypeFromContext 消息的类型, message.Request或message.Notify
+func MessageTypeFromContext(ctx context.Context) (message.Type, bool) {
+	if info, ok := getRequestInfo(ctx); ok {
+		return info.msgType, true
+	}
+
+	return 0, false
+}
+
+// ReceivedAtFromContext session收到这条消息的时间, 可以用来统计排队与处理的耗时
+func ReceivedAtFromContext(ctx context.Context) (time.Time, bool) {
+	if info, ok := getRequestInfo(ctx); ok {
+		return info.receivedAt, true
+	}
+
+	return time.Time{}, false
+}
+
+// RequestIdFromContext 请求id, 格式为"nodeId-sessionId-sequence", 用于在日志中串联同一个请求.
+// sessionId每个进程都从1开始, 因此需要nodeId区分不同的进程, 参考WithNodeId()
+func RequestIdFromContext(ctx context.Context) (string, bool) {
+	if info, ok := getRequestInfo(ctx); ok {
+		return fmt.Sprintf("%s-%d-%d", info.nodeId, info.sessionId, info.sequence), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,68 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/component"
+	"strings"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type MemoryContext struct {
+	ctxChan chan context.Context
+}
+
+func (my *MemoryContext) Capture(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+	my.ctxChan <- ctx
+	return &MemoryEnterResponse{}, nil
+}
+
+func TestSessionFromContext(t *testing.T) {
+	var app, accept = newTestApp(t, WithNodeId("node1"))
+	var capture = &MemoryContext{ctxChan: make(chan context.Context, 1)}
+	if err := app.Register(capture, component.WithName("context")); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	_, _ = c.SendRequest("context.Capture", []byte(`{}`))
+	_ = receiveMessage(t, c)
+
+	var ctx = <-capture.ctxChan
+	var session, ok = SessionFromContext(ctx)
+	if !ok || session != <-sessionChan {
+		t.Fatalf("SessionFromContext() should return the session passed to OnHandShaken(), got %v", session)
+	}
+
+	if GetSessionFromCtx(ctx) != session.(*sessionWrapper).sessionImpl {
+		t.Fatal("GetSessionFromCtx() should return the same session")
+	}
+
+	var requestId, _ = RequestIdFromContext(ctx)
+	if !strings.HasPrefix(requestId, "node1-") || strings.Count(requestId, "-") != 2 {
+		t.Fatalf("unexpected request id %q", requestId)
+	}
+}
+
+func TestDefaultNodeId(t *testing.T) {
+	if defaultNodeId == "" || newNodeId() == defaultNodeId {
+		t.Fatalf("node id should be random, got %q", defaultNodeId)
+	}
+}
@@ -6,6 +6,31 @@ author:     lixianmin
 
 Copyright (C) - All Rights Reserved
 *********************************************************************/
+
+// 同一个App可以挂多个acceptor, Kind()用于在session上区分链接来自哪一种acceptor
+const (
+	KindTcp     = "tcp"
+	KindWs      = "ws"
+	KindHttp    = "http"
+	KindMemory  = "memory"
+	KindUnknown = "unknown"
+)
+
 type Acceptor interface {
 	GetConnChan() chan PlayerConn
 }
+
+// KindAcceptor 可选实现的接口, 没有实现它的acceptor(比如在road之外实现的)的kind为KindUnknown
+type KindAcceptor interface {
+	Acceptor
+	Kind() string
+}
+
+// GetAcceptorKind 返回acceptor的kind
+func GetAcceptorKind(accept Acceptor) string {
+	if item, ok := accept.(KindAcceptor); ok {
+		return item.Kind()
+	}
+
+	return KindUnknown
+}
@@ -1,5 +1,9 @@
 package epoll
 
+import (
+	"time"
+)
+
 /********************************************************************
 created:    2020-09-30
 author:     lixianmin
@@ -8,9 +12,28 @@ Copyright (C) - All Rights Reserved
 *********************************************************************/
 
 type acceptorOptions struct {
-	ConnChanSize     int // GetConnChan()返回
-	ReceivedChanSize int // 每一个PlayerConn拥有一个receivedChan
-	PollBufferSize   int // poll的事件缓冲的长度
+	ConnChanSize     int            // GetConnChan()返回
+	ReceivedChanSize int            // 每一个PlayerConn拥有一个receivedChan
+	PollBufferSize   int            // poll的事件缓冲的长度
+	WatcherCount     int            // gaio watcher的数量, 每个watcher独占一个goroutine处理读完成事件与分帧
+	WatcherByIP      bool           // 按client的ip哈希分配watcher, 默认是round-robin
+	ProxyProtocol    bool           // tcp链接在第一个pomelo帧之前必须携带PROXY protocol v1/v2的header
+	TrustedProxies   trustedProxies // 受信任的代理, 只有来自它们的PROXY header与X-Forwarded-For/X-Real-IP才会被采纳
+
+	WriteDeadline        time.Duration // 每次写入必须在这个时间内完成, 否则关闭链接. 为0时不限制
+	MaxPendingWriteBytes int           // 交给watcher但还没有写完的字节数上限, 超出时关闭链接. 为0时不限制
+
+	AllowedOrigins []string               // websocket允许的Origin列表, 为空时不检查
+	SelectProtocol func(name string) bool // websocket的Sec-WebSocket-Protocol协商, 第一个返回true的协议会被选中
+	UpgradeHook    UpgradeHook            // websocket升级之前的回调, 可以用来做cookie或token验证
+	PingKeepalive  bool                   // websocket使用协议层的ping/pong代替pomelo的heartbeat
+	JsonTextMode   bool                   // websocket的text帧使用json信封, 方便在浏览器console中调试
+	JsonHandshake  bool                   // json模式的client必须先发送{"type":"handshake"}, 否则由server代为补上handshake
+
+	Deflate                        bool // websocket是否协商permessage-deflate压缩
+	DeflateThreshold               int  // 小于这个字节数的消息不压缩
+	DeflateServerNoContextTakeover bool // server发出的每条消息独立压缩, 省内存但压缩率低
+	DeflateClientNoContextTakeover bool // 要求client发出的每条消息独立压缩
 }
 
 type AcceptorOption func(*acceptorOptions)
@@ -38,3 +61,130 @@ func WithPollBufferSize(size int) AcceptorOption {
 		}
 	}
 }
+
+// WithWatcherCount 使用count个gaio watcher分摊链接, 在多核机器上可以设置为runtime.NumCPU()
+func WithWatcherCount(count int) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if count > 0 {
+			options.WatcherCount = count
+		}
+	}
+}
+
+// WithWatcherByIP 按对端ip哈希选择watcher, 而不是round-robin. 注意在L4负载均衡之后对端ip是负载均衡的地址, 此时应该使用round-robin
+func WithWatcherByIP(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.WatcherByIP = enable
+	}
+}
+
+// WithWriteDeadline 每次写入必须在deadline内完成, 否则认为链接已经卡死(比如client不再读数据), session会被关闭
+func WithWriteDeadline(deadline time.Duration) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if deadline >= 0 {
+			options.WriteDeadline = deadline
+		}
+	}
+}
+
+// WithMaxPendingWriteBytes 交给watcher但还没有写完的字节数超过size时, Write()返回ErrTooManyPendingBytes, session会被关闭.
+// 对于HttpAcceptor, 限制的是client还没有确认的下行数据, 默认4MB
+func WithMaxPendingWriteBytes(size int) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if size >= 0 {
+			options.MaxPendingWriteBytes = size
+		}
+	}
+}
+
+// WithProxyProtocol 开启后, TcpAcceptor要求每个链接在第一个pomelo帧之前先发送PROXY protocol v1/v2的header,
+// 并使用其中的地址作为RemoteAddr(). 只采纳来自WithTrustedProxies()中受信任代理的header, 没有设置受信任代理时一律不采纳
+func WithProxyProtocol(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.ProxyProtocol = enable
+	}
+}
+
+// WithTrustedProxies 受信任代理的列表, 支持CIDR与单个IP, 比如: "10.0.0.0/8", "192.168.1.1".
+// WsAcceptor只有在直连的peer是受信任代理时, 才会使用X-Forwarded-For与X-Real-IP作为RemoteAddr()
+func WithTrustedProxies(proxies ...string) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.TrustedProxies = append(options.TrustedProxies, parseTrustedProxies(proxies)...)
+	}
+}
+
+// WithAllowedOrigins websocket升级时检查Origin头, 支持"*", 完整的origin以及"*.example.com"这样的通配host
+func WithAllowedOrigins(origins ...string) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.AllowedOrigins = append(options.AllowedOrigins, origins...)
+	}
+}
+
+// WithSubprotocol 协商Sec-WebSocket-Protocol, client请求的协议会按顺序传给selector, 第一个返回true的协议会被选中
+func WithSubprotocol(selector func(name string) bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if selector != nil {
+			options.SelectProtocol = selector
+		}
+	}
+}
+
+// WithUpgradeHook websocket升级之前调用hook, hook返回error会拒绝升级, 返回的value可以通过Session.UpgradeData().Value读取
+func WithUpgradeHook(hook UpgradeHook) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if hook != nil {
+			options.UpgradeHook = hook
+		}
+	}
+}
+
+// WithPingKeepalive websocket使用协议层的ping/pong帧保活, 代替pomelo的heartbeat包, 浏览器会自动回复pong
+func WithPingKeepalive(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.PingKeepalive = enable
+	}
+}
+
+// WithDeflate websocket协商permessage-deflate压缩, 浏览器原生支持解压, 不再需要在js中解压应用层的zlib数据
+func WithDeflate(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.Deflate = enable
+	}
+}
+
+// WithDeflateThreshold 小于size字节的消息不压缩, 因为小消息压缩之后往往更大, 还白白浪费cpu
+func WithDeflateThreshold(size int) AcceptorOption {
+	return func(options *acceptorOptions) {
+		if size >= 0 {
+			options.DeflateThreshold = size
+		}
+	}
+}
+
+// WithDeflateNoContextTakeover 设置server/client两个方向是否禁用context takeover. 禁用后每条消息独立压缩,
+// 每个链接可以省下32KB左右的滑动窗口内存, 但压缩率会下降
+func WithDeflateNoContextTakeover(server bool, client bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.DeflateServerNoContextTakeover = server
+		options.DeflateClientNoContextTakeover = client
+	}
+}
+
+// WithJsonTextMode 开启后websocket的text帧使用json信封: {"id":1,"route":"room.enter","body":{...}},
+// 与binary帧走相同的handler流程, 回复与推送也以json的text帧返回, 方便QA在浏览器console中调试.
+//
+// 默认情况下, 收到第一个text帧时server会替client补上pomelo的handshake与handshakeAck, client不需要关心握手;
+// 如果需要client显式握手, 请同时使用WithJsonHandshake(true)
+func WithJsonTextMode(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.JsonTextMode = enable
+	}
+}
+
+// WithJsonHandshake 开启后json模式的client必须先发送{"type":"handshake","body":{...}}, body会原样作为pomelo的handshake数据,
+// server回复{"type":"handshake","body":{...}}; 握手之前收到的其它text帧会导致链接被关闭
+func WithJsonHandshake(enable bool) AcceptorOption {
+	return func(options *acceptorOptions) {
+		options.JsonHandshake = enable
+	}
+}
@@ -0,0 +1,301 @@
+package epoll
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/lixianmin/logo"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+部分企业网络会屏蔽websocket, HttpAcceptor是基于普通http请求的后备方案, 数据格式仍然是pomelo的包:
+1. POST {servePath}/open               创建链接, 返回 {"sid":"..."}, 之后的请求都需要带上?sid=
+2. POST {servePath}/send?sid=          body是一个或多个pomelo包(application/octet-stream)
+3. GET  {servePath}/poll?sid=&ack=    long-poll, 返回积压的下行数据; 超时没有数据时返回204
+4. GET  {servePath}/sse?sid=&ack=     Server-Sent Events, 每个event的data是base64编码的下行数据
+5. POST {servePath}/close?sid=         主动关闭链接
+
+sid相当于链接的凭证, 因此使用crypto/rand生成
+
+下行数据在client确认之前一直保留在server, 并且总量受WithMaxPendingWriteBytes()限制:
+1. poll的响应头X-Road-Seq是下行数据流的序号, client在下一次poll时通过ack=带回来, 上一次的响应丢失时数据会重发
+2. sse的每个event以序号作为id, 断线重连时浏览器会自动带上Last-Event-ID
+3. 链接关闭后sid会保留到最后的下行数据(比如kick包)被确认, 最长httpCloseGrace, 之后的poll返回410
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const (
+	httpPollTimeout     = 25 * time.Second // 小于大部分代理默认的60秒超时
+	httpSseKeepalive    = 15 * time.Second
+	httpCloseGrace      = 10 * time.Second // 链接关闭后, 等待client取走最后的下行数据的时间
+	httpMaxRequestBytes = 1 << 20
+	httpMaxOutputBytes  = 4 << 20 // client长时间不poll时, 积压的下行数据的默认上限
+	httpSeqHeader       = "X-Road-Seq"
+)
+
+type HttpAcceptor struct {
+	options  acceptorOptions
+	connChan chan PlayerConn
+	conns    sync.Map // sid => *HttpConn
+}
+
+func NewHttpAcceptor(serveMux IServeMux, servePath string, opts ...AcceptorOption) *HttpAcceptor {
+	var options = acceptorOptions{
+		ConnChanSize:         16,
+		ReceivedChanSize:     16,
+		PollBufferSize:       1024,
+		MaxPendingWriteBytes: httpMaxOutputBytes,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var my = &HttpAcceptor{
+		options:  options,
+		connChan: make(chan PlayerConn, options.ConnChanSize),
+	}
+
+	servePath = strings.TrimSuffix(servePath, "/")
+	serveMux.HandleFunc(servePath+"/open", my.handleOpen)
+	serveMux.HandleFunc(servePath+"/send", my.handleSend)
+	serveMux.HandleFunc(servePath+"/poll", my.handlePoll)
+	serveMux.HandleFunc(servePath+"/sse", my.handleSse)
+	serveMux.HandleFunc(servePath+"/close", my.handleClose)
+	return my
+}
+
+func (my *HttpAcceptor) handleOpen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkOrigin(my.options.AllowedOrigins, r) {
+		http.Error(w, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+
+	var data = newUpgradeData(r)
+	if my.options.UpgradeHook != nil {
+		var value, err = my.options.UpgradeHook(r)
+		if err != nil {
+			writeUpgradeRejection(w, err)
+			return
+		}
+
+		data.Value = value
+	}
+
+	var sid, err = newHttpSid()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var peer net.Addr = httpAddr(r.RemoteAddr)
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		peer = addr
+	}
+
+	var remoteAddr = my.options.TrustedProxies.resolveForwardedAddr(peer, r.Header)
+	var conn = newHttpConn(sid, remoteAddr, data, my.options.ReceivedChanSize, my.options.MaxPendingWriteBytes, func() {
+		my.conns.Delete(sid)
+	})
+
+	my.conns.Store(sid, conn)
+	select {
+	case my.connChan <- conn:
+	case <-r.Context().Done():
+		_ = conn.Close()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"sid": sid})
+}
+
+func (my *HttpAcceptor) handleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var conn = my.fetchConn(w, r)
+	if conn == nil {
+		return
+	}
+
+	var body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, httpMaxRequestBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := conn.onReceiveData(body); err != nil {
+		conn.sendErrorMessage(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (my *HttpAcceptor) handlePoll(w http.ResponseWriter, r *http.Request) {
+	var conn = my.fetchConn(w, r)
+	if conn == nil {
+		return
+	}
+
+	var ack = parseHttpAck(r.URL.Query().Get("ack"))
+	var timer = time.NewTimer(httpPollTimeout)
+	defer timer.Stop()
+
+	for {
+		var data, seq = conn.takeOutputs(ack)
+		w.Header().Set(httpSeqHeader, strconv.FormatInt(seq, 10))
+		if len(data) > 0 {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(data)
+			return
+		}
+
+		// 关闭前写入的数据都取走之后, 才告诉client链接已经关闭
+		if conn.wc.IsClosed() {
+			http.Error(w, "connection is closed", http.StatusGone)
+			return
+		}
+
+		// 已经确认过的数据不需要再确认
+		ack = seq
+		select {
+		case <-conn.outputSignal:
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-conn.wc.C():
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (my *HttpAcceptor) handleSse(w http.ResponseWriter, r *http.Request) {
+	var conn = my.fetchConn(w, r)
+	if conn == nil {
+		return
+	}
+
+	var flusher, ok = w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// 浏览器的EventSource断线重连时会自动带上Last-Event-ID
+	var ack = parseHttpAck(r.URL.Query().Get("ack"))
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		ack = parseHttpAck(lastEventId)
+	}
+
+	var ticker = time.NewTicker(httpSseKeepalive)
+	defer ticker.Stop()
+
+	for {
+		if data, seq := conn.takeOutputs(ack); len(data) > 0 {
+			var event = "id: " + strconv.FormatInt(seq, 10) + "\ndata: " + base64.StdEncoding.EncodeToString(data) + "\n\n"
+			if _, err := w.Write([]byte(event)); err != nil {
+				return
+			}
+
+			flusher.Flush()
+			ack = seq
+		}
+
+		// 关闭前写入的数据都发出去之后再发送close事件, sse没有后续的请求来确认, 因此在这里确认
+		if conn.wc.IsClosed() {
+			_, _ = w.Write([]byte("event: close\ndata: \n\n"))
+			flusher.Flush()
+			conn.takeOutputs(ack)
+			return
+		}
+
+		select {
+		case <-conn.outputSignal:
+		case <-ticker.C:
+			// sse的注释行, 防止中间代理因为长时间没有数据而断开
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-conn.wc.C():
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (my *HttpAcceptor) handleClose(w http.ResponseWriter, r *http.Request) {
+	var conn = my.fetchConn(w, r)
+	if conn == nil {
+		return
+	}
+
+	conn.sendErrorMessage(errHttpConnClosedByClient)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (my *HttpAcceptor) fetchConn(w http.ResponseWriter, r *http.Request) *HttpConn {
+	var sid = r.URL.Query().Get("sid")
+	if v, ok := my.conns.Load(sid); ok {
+		return v.(*HttpConn)
+	}
+
+	http.Error(w, "sid is not found", http.StatusGone)
+	return nil
+}
+
+func (my *HttpAcceptor) GetConnChan() chan PlayerConn {
+	return my.connChan
+}
+
+func (my *HttpAcceptor) Kind() string {
+	return KindHttp
+}
+
+// parseHttpAck 没有带ack或者ack无效时返回-1
+func parseHttpAck(text string) int64 {
+	var ack, err = strconv.ParseInt(text, 10, 64)
+	if err != nil || ack < 0 {
+		return -1
+	}
+
+	return ack
+}
+
+func newHttpSid() (string, error) {
+	var buff = make([]byte, 16)
+	if _, err := rand.Read(buff); err != nil {
+		logo.Warn("failed to generate sid, err=%q", err)
+		return "", err
+	}
+
+	return hex.EncodeToString(buff), nil
+}
@@ -0,0 +1,159 @@
+package epoll
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func openTestHttp(t *testing.T, opts ...AcceptorOption) (*httptest.Server, string, *HttpConn) {
+	var mux = http.NewServeMux()
+	var accept = NewHttpAcceptor(mux, "/http", opts...)
+	var server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var response, err = http.Post(server.URL+"/http/open", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Sid string `json:"sid"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	var conn = (<-accept.GetConnChan()).(*HttpConn)
+	t.Cleanup(func() { _ = conn.Close() })
+	return server, result.Sid, conn
+}
+
+func pollTestHttp(t *testing.T, server *httptest.Server, sid string, ack string) (string, string) {
+	var url = server.URL + "/http/poll?sid=" + sid
+	if ack != "" {
+		url += "&ack=" + ack
+	}
+
+	var response, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body), response.Header.Get(httpSeqHeader)
+}
+
+func TestHttpPollAck(t *testing.T) {
+	var server, sid, conn = openTestHttp(t)
+	var steps = []struct {
+		write string
+		ack   string
+		data  string
+		seq   string
+	}{
+		{write: "abc", data: "abc", seq: "3"},
+		{write: "de", ack: "0", data: "abcde", seq: "5"}, // 上一次的响应丢失, 从ack=0开始重发
+		{write: "f", ack: "5", data: "f", seq: "6"},
+		{write: "g", data: "g", seq: "7"}, // 没有带ack时, 认为之前交出去的数据都已经收到
+	}
+
+	for i, step := range steps {
+		if _, err := conn.Write([]byte(step.write)); err != nil {
+			t.Fatal(err)
+		}
+
+		var data, seq = pollTestHttp(t, server, sid, step.ack)
+		if data != step.data || seq != step.seq {
+			t.Fatalf("step %d: data=%q seq=%s, expect data=%q seq=%s", i, data, seq, step.data, step.seq)
+		}
+	}
+}
+
+func TestHttpOutputsLimit(t *testing.T) {
+	var server, sid, conn = openTestHttp(t, WithMaxPendingWriteBytes(4))
+	if _, err := conn.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	// client不来poll时, 积压的数据不能无限增长
+	if _, err := conn.Write([]byte("de")); err != ErrTooManyPendingBytes {
+		t.Fatalf("expect ErrTooManyPendingBytes, got err=%v", err)
+	}
+
+	// 交出去但还没有确认的数据仍然占用额度, 确认之后才释放
+	_, _ = pollTestHttp(t, server, sid, "")
+	if _, err := conn.Write([]byte("de")); err != ErrTooManyPendingBytes {
+		t.Fatalf("expect ErrTooManyPendingBytes before ack, got err=%v", err)
+	}
+
+	if data, _ := conn.takeOutputs(3); data != nil {
+		t.Fatalf("expect no data after ack, got %q", data)
+	}
+
+	if _, err := conn.Write([]byte("de")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHttpPollAfterClose 模拟session写入kick包之后立即关闭链接, client仍然可以poll到kick包
+func TestHttpPollAfterClose(t *testing.T) {
+	var server, sid, conn = openTestHttp(t)
+	if _, err := conn.Write([]byte("kick")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	var data, seq = pollTestHttp(t, server, sid, "")
+	if data != "kick" || seq != "4" {
+		t.Fatalf("expect the kick packet, got data=%q seq=%s", data, seq)
+	}
+
+	// 上一次的响应丢失时, 仍然可以重新取到数据
+	if data, _ = pollTestHttp(t, server, sid, "0"); data != "kick" {
+		t.Fatalf("expect the kick packet again, got data=%q", data)
+	}
+
+	// 确认之后返回链接已经关闭, 并且sid被移除
+	if data, _ = pollTestHttp(t, server, sid, "4"); !strings.Contains(data, "connection is closed") {
+		t.Fatalf("expect connection is closed, got data=%q", data)
+	}
+
+	if data, _ = pollTestHttp(t, server, sid, "4"); !strings.Contains(data, "sid is not found") {
+		t.Fatalf("expect sid is not found, got data=%q", data)
+	}
+}
+
+func TestHttpRemoteAddrFallback(t *testing.T) {
+	var accept = NewHttpAcceptor(http.NewServeMux(), "/http")
+	var request = httptest.NewRequest(http.MethodPost, "/http/open", nil)
+	request.RemoteAddr = "@"
+
+	var recorder = httptest.NewRecorder()
+	accept.handleOpen(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status=%d", recorder.Code)
+	}
+
+	var conn = <-accept.GetConnChan()
+	if addr := conn.RemoteAddr(); addr == nil || addr.String() != "@" {
+		t.Fatalf("unexpected remote addr: %v", addr)
+	}
+}
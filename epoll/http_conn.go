@@ -0,0 +1,183 @@
+package epoll
+
+import (
+	"errors"
+	"github.com/lixianmin/got/loom"
+	"net"
+	"sync"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+var (
+	errHttpConnClosed         = errors.New("http connection is closed")
+	errHttpConnClosedByClient = errors.New("http connection is closed by client")
+)
+
+type (
+	HttpConn struct {
+		sid          string
+		remoteAddr   net.Addr
+		upgradeData  *UpgradeData
+		receivedChan chan Message
+		onClosed     func()
+		releaseOnce  sync.Once
+		wc           loom.WaitClose
+
+		inputLock sync.Mutex // 多个send请求可能并发到达
+		input     *Buffer
+
+		outputLock     sync.Mutex
+		outputs        []byte        // client还没有确认(ack)的下行数据
+		outputSeq      int64         // outputs[0]在下行数据流中的序号, 即client已经确认的字节数
+		deliveredSeq   int64         // 已经交给poll或sse的数据结束处的序号
+		maxOutputBytes int           // outputs的长度上限, 超出时Write()返回ErrTooManyPendingBytes. 为0时不限制
+		outputSignal   chan struct{} // 有新的下行数据时通知poll或sse
+	}
+
+	// httpAddr r.RemoteAddr无法解析为tcp地址时(比如经过了unix socket), 原样保留它
+	httpAddr string
+)
+
+func newHttpConn(sid string, remoteAddr net.Addr, upgradeData *UpgradeData, receivedChanSize int, maxOutputBytes int, onClosed func()) *HttpConn {
+	var my = &HttpConn{
+		sid:            sid,
+		remoteAddr:     remoteAddr,
+		upgradeData:    upgradeData,
+		receivedChan:   make(chan Message, receivedChanSize),
+		onClosed:       onClosed,
+		input:          &Buffer{},
+		maxOutputBytes: maxOutputBytes,
+		outputSignal:   make(chan struct{}, 1),
+	}
+
+	return my
+}
+
+func (my *HttpConn) sendErrorMessage(err error) {
+	my.writeMessage(Message{Err: err})
+}
+
+func (my *HttpConn) GetReceivedChan() <-chan Message {
+	return my.receivedChan
+}
+
+func (my *HttpConn) onReceiveData(buff []byte) error {
+	my.inputLock.Lock()
+	defer my.inputLock.Unlock()
+
+	var input = my.input
+	var _, err = input.Write(buff)
+	if err != nil {
+		return err
+	}
+
+	return fetchFrames(input, my.writeMessage)
+}
+
+// Write 下行数据先积压在outputs中, 等待client通过poll或sse取走. client长时间不来取数据时, 积压超过上限会返回错误, 由session关闭链接
+func (my *HttpConn) Write(b []byte) (int, error) {
+	if my.wc.IsClosed() {
+		return 0, errHttpConnClosed
+	}
+
+	my.outputLock.Lock()
+	if my.maxOutputBytes > 0 && len(my.outputs)+len(b) > my.maxOutputBytes {
+		my.outputLock.Unlock()
+		return 0, ErrTooManyPendingBytes
+	}
+
+	my.outputs = append(my.outputs, b...)
+	my.outputLock.Unlock()
+
+	select {
+	case my.outputSignal <- struct{}{}:
+	default:
+	}
+
+	return len(b), nil
+}
+
+// takeOutputs 丢弃序号ack之前(client已经收到)的数据, 返回ack之后积压的数据, 以及这些数据结束处的序号.
+// client在下一次请求时把这个序号作为ack带回来, 这样上一次的响应丢失时数据会被重新发送.
+// ack小于0表示client没有带ack, 此时认为之前交出去的数据都已经收到
+func (my *HttpConn) takeOutputs(ack int64) ([]byte, int64) {
+	my.outputLock.Lock()
+	defer my.outputLock.Unlock()
+
+	var endSeq = my.outputSeq + int64(len(my.outputs))
+	if ack < 0 || ack > my.deliveredSeq {
+		ack = my.deliveredSeq
+	}
+
+	if ack > my.outputSeq {
+		var acked = int(ack - my.outputSeq)
+		my.outputs = append(my.outputs[:0], my.outputs[acked:]...)
+		my.outputSeq = ack
+	}
+
+	if len(my.outputs) == 0 {
+		if my.wc.IsClosed() {
+			my.release()
+		}
+		return nil, endSeq
+	}
+
+	my.deliveredSeq = endSeq
+	var data = make([]byte, len(my.outputs))
+	copy(data, my.outputs)
+
+	return data, endSeq
+}
+
+func (my *HttpConn) writeMessage(msg Message) {
+	select {
+	case my.receivedChan <- msg:
+	case <-my.wc.C():
+	}
+}
+
+// Close 关闭之前写入的数据(比如kick包)可能还没有被client取走, 因此sid会保留到这些数据被确认, 或者超过httpCloseGrace
+func (my *HttpConn) Close() error {
+	return my.wc.Close(func() error {
+		my.outputLock.Lock()
+		var isAcked = len(my.outputs) == 0
+		my.outputLock.Unlock()
+
+		if isAcked {
+			my.release()
+		} else {
+			time.AfterFunc(httpCloseGrace, my.release)
+		}
+		return nil
+	})
+}
+
+// release 从HttpAcceptor中移除sid, 之后的请求都会返回sid is not found
+func (my *HttpConn) release() {
+	my.releaseOnce.Do(my.onClosed)
+}
+
+// RemoteAddr returns the remote address, X-Forwarded-For and X-Real-IP are used if the peer is a trusted proxy.
+func (my *HttpConn) RemoteAddr() net.Addr {
+	return my.remoteAddr
+}
+
+// UpgradeData 创建链接(open)请求中的headers, query, cookies等数据
+func (my *HttpConn) UpgradeData() *UpgradeData {
+	return my.upgradeData
+}
+
+func (addr httpAddr) Network() string {
+	return "tcp"
+}
+
+func (addr httpAddr) String() string {
+	return string(addr)
+}
@@ -0,0 +1,78 @@
+package epoll
+
+import (
+	"errors"
+	"github.com/lixianmin/got/loom"
+	"net"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+MemoryAcceptor不监听任何端口, 而是通过net.Pipe()在进程内创建链接, 主要用于
+单元测试: handler包可以在不打开socket的情况下, 完整地走一遍handshake, request,
+push, kick等流程
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+var ErrAcceptorClosed = errors.New("acceptor is closed")
+
+type MemoryAcceptor struct {
+	connChan         chan PlayerConn
+	receivedChanSize int
+	wc               loom.WaitClose
+}
+
+func NewMemoryAcceptor(opts ...AcceptorOption) *MemoryAcceptor {
+	var options = acceptorOptions{
+		ConnChanSize:     16,
+		ReceivedChanSize: 16,
+		PollBufferSize:   1024,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var my = &MemoryAcceptor{
+		connChan:         make(chan PlayerConn, options.ConnChanSize),
+		receivedChanSize: options.ReceivedChanSize,
+	}
+
+	return my
+}
+
+// Dial 创建一条进程内链接, 返回client端的net.Conn, server端的PlayerConn会被投递到GetConnChan()中
+func (my *MemoryAcceptor) Dial() (net.Conn, error) {
+	if my.wc.IsClosed() {
+		return nil, ErrAcceptorClosed
+	}
+
+	var serverSide, clientSide = net.Pipe()
+	var connection = newMemoryConn(serverSide, my.receivedChanSize)
+
+	select {
+	case my.connChan <- connection:
+		go connection.goReceive()
+		go connection.goWrite()
+		return clientSide, nil
+	case <-my.wc.C():
+		_ = serverSide.Close()
+		_ = clientSide.Close()
+		return nil, ErrAcceptorClosed
+	}
+}
+
+func (my *MemoryAcceptor) GetConnChan() chan PlayerConn {
+	return my.connChan
+}
+
+func (my *MemoryAcceptor) Close() error {
+	return my.wc.Close(nil)
+}
+
+func (my *MemoryAcceptor) Kind() string {
+	return KindMemory
+}
@@ -0,0 +1,135 @@
+package epoll
+
+import (
+	"github.com/lixianmin/got/loom"
+	"net"
+	"sync/atomic"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// memoryWriteBufferSize net.Pipe()是同步的, 写缓冲用于避免client端不读push时阻塞server端的sender
+const memoryWriteBufferSize = 1024
+
+type MemoryConn struct {
+	conn         net.Conn
+	receivedChan chan Message
+	outputs      chan []byte
+	input        *Buffer
+	pendingBytes int64 // 写缓冲中还没有写入net.Pipe()的字节数
+	wc           loom.WaitClose
+}
+
+func newMemoryConn(conn net.Conn, receivedChanSize int) *MemoryConn {
+	var my = &MemoryConn{
+		conn:         conn,
+		receivedChan: make(chan Message, receivedChanSize),
+		outputs:      make(chan []byte, memoryWriteBufferSize),
+		input:        &Buffer{},
+	}
+
+	return my
+}
+
+// goWrite 把写缓冲中的数据依次写入net.Pipe()
+func (my *MemoryConn) goWrite() {
+	defer loom.DumpIfPanic()
+
+	var closeChan = my.wc.C()
+	for {
+		select {
+		case data := <-my.outputs:
+			var _, err = my.conn.Write(data)
+			atomic.AddInt64(&my.pendingBytes, -int64(len(data)))
+			if err != nil {
+				my.sendErrorMessage(err)
+				return
+			}
+		case <-closeChan:
+			return
+		}
+	}
+}
+
+// goReceive 没有gaio的watcher, 因此单独起一个goroutine读取数据
+func (my *MemoryConn) goReceive() {
+	defer loom.DumpIfPanic()
+
+	var buff = make([]byte, 4096)
+	for {
+		var n, err = my.conn.Read(buff)
+		if err != nil {
+			my.sendErrorMessage(err)
+			return
+		}
+
+		if err := my.onReceiveData(buff[:n]); err != nil {
+			my.sendErrorMessage(err)
+			return
+		}
+	}
+}
+
+func (my *MemoryConn) sendErrorMessage(err error) {
+	my.writeMessage(Message{Err: err})
+}
+
+func (my *MemoryConn) GetReceivedChan() <-chan Message {
+	return my.receivedChan
+}
+
+func (my *MemoryConn) onReceiveData(buff []byte) error {
+	var input = my.input
+	var _, err = input.Write(buff)
+	if err != nil {
+		return err
+	}
+
+	return fetchFrames(input, my.writeMessage)
+}
+
+// Write 数据先复制到写缓冲中, 只有写缓冲满了才会阻塞
+func (my *MemoryConn) Write(b []byte) (int, error) {
+	var data = append([]byte(nil), b...)
+	atomic.AddInt64(&my.pendingBytes, int64(len(data)))
+	select {
+	case my.outputs <- data:
+		return len(b), nil
+	case <-my.wc.C():
+		atomic.AddInt64(&my.pendingBytes, -int64(len(data)))
+		return 0, net.ErrClosed
+	}
+}
+
+// PendingWriteBytes 写缓冲中还没有写入net.Pipe()的字节数
+func (my *MemoryConn) PendingWriteBytes() int64 {
+	return atomic.LoadInt64(&my.pendingBytes)
+}
+
+func (my *MemoryConn) writeMessage(msg Message) {
+	select {
+	case my.receivedChan <- msg:
+	case <-my.wc.C():
+	}
+}
+
+func (my *MemoryConn) Close() error {
+	return my.wc.Close(func() error {
+		return my.conn.Close()
+	})
+}
+
+// LocalAddr returns the local address.
+func (my *MemoryConn) LocalAddr() net.Addr {
+	return my.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote address.
+func (my *MemoryConn) RemoteAddr() net.Addr {
+	return my.conn.RemoteAddr()
+}
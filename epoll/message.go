@@ -1,5 +1,9 @@
 package epoll
 
+import (
+	"github.com/lixianmin/road/util/bufferpool"
+)
+
 /********************************************************************
 created:    2020-09-06
 author:     lixianmin
@@ -8,6 +12,15 @@ Copyright (C) - All Rights Reserved
 *********************************************************************/
 
 type Message struct {
-	Data []byte
-	Err  error
+	Data        []byte
+	Err         error
+	IsKeepalive bool // 协议层的keepalive(比如websocket的ping/pong帧), 只用于刷新session的心跳时间, Data与Err都为空
+	isPooled    bool // Data来自bufferpool, 处理完之后需要Release()
+}
+
+// Release 处理完Message之后归还Data的内存, 之后不能再引用Data以及从Data中切出来的任何slice
+func (msg Message) Release() {
+	if msg.isPooled {
+		bufferpool.Put(msg.Data)
+	}
 }
@@ -5,6 +5,8 @@ import (
 	"github.com/lixianmin/got/loom"
 	"github.com/lixianmin/logo"
 	"github.com/xtaci/gaio"
+	"hash/fnv"
+	"net"
 	"sync/atomic"
 )
 
@@ -14,24 +16,38 @@ author:     lixianmin
 
 Copyright (C) - All Rights Reserved
 *********************************************************************/
+
+// PlayerAcceptor 每个gaio.Watcher有一个独立的goWatcher()处理读完成事件与分帧. 开启多个watcher后, 链接会被分摊到不同的watcher上,
+// 从而让读与分帧的开销可以分散到多个cpu核上
 type PlayerAcceptor struct {
-	watcher  *gaio.Watcher
-	isClosed int32
+	watchers    []*gaio.Watcher
+	nextIndex   uint32
+	watcherByIP bool
+	isClosed    int32
 }
 
-func newPlayerAcceptor() *PlayerAcceptor {
-	var watcher, err = gaio.NewWatcher()
-	if err != nil {
-		var message = fmt.Sprintf("watcher is %v, err=%q", watcher, err)
-		panic(message)
-		return nil
+func newPlayerAcceptor(options acceptorOptions) *PlayerAcceptor {
+	var count = options.WatcherCount
+	if count <= 0 {
+		count = 1
 	}
 
 	var my = &PlayerAcceptor{
-		watcher: watcher,
+		watchers:    make([]*gaio.Watcher, count),
+		watcherByIP: options.WatcherByIP,
+	}
+
+	for i := 0; i < count; i++ {
+		var watcher, err = gaio.NewWatcher()
+		if err != nil {
+			var message = fmt.Sprintf("watcher is %v, err=%q", watcher, err)
+			panic(message)
+		}
+
+		my.watchers[i] = watcher
+		go my.goWatcher(watcher)
 	}
 
-	go my.goWatcher(watcher)
 	return my
 }
 
@@ -47,6 +63,13 @@ func (my *PlayerAcceptor) goWatcher(watcher *gaio.Watcher) {
 		}
 
 		for _, item := range results {
+			// 写完(或者失败)之后更新积压的字节数, 归还池化的buffer
+			if item.Operation == gaio.OpWrite {
+				if completer, ok := item.Context.(writeCompleter); ok {
+					completer.onWriteCompleted(item.Buffer)
+				}
+			}
+
 			if item.Error != nil {
 				if playerConn, ok := item.Context.(PlayerConn); ok {
 					playerConn.sendErrorMessage(item.Error)
@@ -79,8 +102,28 @@ func (my *PlayerAcceptor) goWatcher(watcher *gaio.Watcher) {
 	}
 }
 
-func (my *PlayerAcceptor) getWatcher() *gaio.Watcher {
-	return my.watcher
+// getWatcher 默认round-robin分配watcher; 开启WatcherByIP后, 同一个ip的链接总是分配到同一个watcher
+func (my *PlayerAcceptor) getWatcher(conn net.Conn) *gaio.Watcher {
+	var count = uint32(len(my.watchers))
+	if count == 1 {
+		return my.watchers[0]
+	}
+
+	if my.watcherByIP {
+		if ip := addrToIP(conn.RemoteAddr()); ip != nil {
+			// ipv4地址可能是4字节或者16字节的形式, 统一之后再hash
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+
+			var h = fnv.New32a()
+			_, _ = h.Write(ip)
+			return my.watchers[h.Sum32()%count]
+		}
+	}
+
+	var index = atomic.AddUint32(&my.nextIndex, 1)
+	return my.watchers[index%count]
 }
 
 func (my *PlayerAcceptor) Close() error {
@@ -0,0 +1,92 @@
+package epoll
+
+import (
+	"github.com/xtaci/gaio"
+	"net"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// addrConn 只用于getWatcher()读取RemoteAddr()
+type addrConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (my *addrConn) RemoteAddr() net.Addr {
+	return my.remoteAddr
+}
+
+func newTestPlayerAcceptor(t *testing.T, options acceptorOptions) *PlayerAcceptor {
+	var my = newPlayerAcceptor(options)
+	t.Cleanup(func() {
+		_ = my.Close()
+		for _, watcher := range my.watchers {
+			_ = watcher.Close()
+		}
+	})
+
+	return my
+}
+
+func TestGetWatcherRoundRobin(t *testing.T) {
+	const count = 4
+	var acceptor = newTestPlayerAcceptor(t, acceptorOptions{WatcherCount: count})
+	var conn = &addrConn{remoteAddr: &net.TCPAddr{IP: net.ParseIP("1.1.1.1"), Port: 1000}}
+
+	var counters = make(map[*gaio.Watcher]int)
+	for i := 0; i < count*3; i++ {
+		counters[acceptor.getWatcher(conn)]++
+	}
+
+	if len(counters) != count {
+		t.Fatalf("expect conns spread across %d watchers, got %d", count, len(counters))
+	}
+
+	for watcher, counter := range counters {
+		if counter != 3 {
+			t.Fatalf("watcher %p got %d conns, expect 3", watcher, counter)
+		}
+	}
+}
+
+func TestGetWatcherByIP(t *testing.T) {
+	const count = 4
+	var acceptor = newTestPlayerAcceptor(t, acceptorOptions{WatcherCount: count, WatcherByIP: true})
+
+	var counters = make(map[*gaio.Watcher]int)
+	for i := 0; i < 64; i++ {
+		var ip = net.IPv4(10, 0, byte(i/256), byte(i%256))
+		var watcher = acceptor.getWatcher(&addrConn{remoteAddr: &net.TCPAddr{IP: ip, Port: 1000 + i}})
+		counters[watcher]++
+
+		// 同一个ip的不同端口, 以及4字节与16字节形式的ip, 都要分配到同一个watcher
+		var sameIPs = []net.Addr{
+			&net.TCPAddr{IP: ip, Port: 2000 + i},
+			&net.TCPAddr{IP: ip.To4(), Port: 3000 + i},
+			httpAddr(ip.String() + ":4000"),
+		}
+
+		for _, addr := range sameIPs {
+			if acceptor.getWatcher(&addrConn{remoteAddr: addr}) != watcher {
+				t.Fatalf("%s should map to the same watcher as %s", addr, ip)
+			}
+		}
+	}
+
+	if len(counters) != count {
+		t.Fatalf("expect ips spread across %d watchers, got %d", count, len(counters))
+	}
+
+	// 解析不出ip时退回到round-robin
+	var conn = &addrConn{remoteAddr: httpAddr("@")}
+	if acceptor.getWatcher(conn) == acceptor.getWatcher(conn) {
+		t.Fatal("expect round-robin without ip")
+	}
+}
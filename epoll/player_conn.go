@@ -1,6 +1,10 @@
 package epoll
 
-import "net"
+import (
+	"github.com/gobwas/ws"
+	"github.com/lixianmin/road/util/bufferpool"
+	"net"
+)
 
 /********************************************************************
 created:    2020-09-06
@@ -18,3 +22,54 @@ type PlayerConn interface {
 	Close() error
 	RemoteAddr() net.Addr
 }
+
+// CloseWriter 支持close握手的链接, 比如websocket
+type CloseWriter interface {
+	WriteClose(code int, reason string) error
+}
+
+// WriteNormalClose 链接支持close握手时(比如websocket), 发送正常关闭(1000)的close帧; 其它链接什么都不做
+func WriteNormalClose(conn PlayerConn, reason string) error {
+	if closer, ok := conn.(CloseWriter); ok {
+		return closer.WriteClose(int(ws.StatusNormalClosure), reason)
+	}
+
+	return nil
+}
+
+// PooledWriter 可以直接接管bufferpool中buffer的链接, 省掉Write()中的一次copy
+type PooledWriter interface {
+	// WritePooled 无论成功与否, buff的所有权都交给了链接, 调用方不能再使用它
+	WritePooled(buff []byte) error
+}
+
+// WritePooledBuffer buff来自bufferpool, 链接支持PooledWriter时直接交出所有权; 否则Write()之后立即归还
+func WritePooledBuffer(conn PlayerConn, buff []byte) error {
+	if writer, ok := conn.(PooledWriter); ok {
+		return writer.WritePooled(buff)
+	}
+
+	var _, err = conn.Write(buff)
+	bufferpool.Put(buff)
+	return err
+}
+
+// PendingWriter 可以查询还没有写完的字节数的链接
+type PendingWriter interface {
+	PendingWriteBytes() int64
+}
+
+// GetPendingWriteBytes 链接中还没有写完的字节数, 链接不支持PendingWriter时返回0
+func GetPendingWriteBytes(conn PlayerConn) int64 {
+	if writer, ok := conn.(PendingWriter); ok {
+		return writer.PendingWriteBytes()
+	}
+
+	return 0
+}
+
+// Pinger 支持协议层ping/pong保活的链接, PingKeepalive()返回true时, session用WritePing()代替pomelo的heartbeat
+type Pinger interface {
+	PingKeepalive() bool
+	WritePing() error
+}
@@ -0,0 +1,155 @@
+package epoll
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+PROXY protocol v1/v2, 用于在L4负载均衡之后拿到client的真实地址
+参考: https://www.haproxy.org/download/2.6/doc/proxy-protocol.txt
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const (
+	proxyV1MaxLength   = 107
+	proxyV2HeadLength  = 16
+	proxyV2MaxLength   = 536 // 与haproxy的建议一致, 超过这个长度的header视为非法, 避免一直缓存数据
+	proxyV2CmdLocal    = 0x00
+	proxyV2CmdProxy    = 0x01
+	proxyV2FamilyTcp4  = 0x11
+	proxyV2FamilyTcp6  = 0x21
+	proxyV2AddrLength4 = 12
+	proxyV2AddrLength6 = 36
+)
+
+var (
+	proxyV1Signature = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+var ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// parseProxyHeader 从data中解析PROXY protocol的header:
+// 1. size == 0 && err == nil, 说明数据还不完整, 需要等待后续数据
+// 2. addr == nil, 说明是LOCAL/UNKNOWN之类不携带地址的header, 此时应该沿用链接本身的地址
+func parseProxyHeader(data []byte) (addr net.Addr, size int, err error) {
+	if isPrefixOf(data, proxyV2Signature) {
+		if len(data) < proxyV2HeadLength {
+			return nil, 0, nil
+		}
+
+		return parseProxyHeaderV2(data)
+	}
+
+	if isPrefixOf(data, proxyV1Signature) {
+		if len(data) < len(proxyV1Signature) {
+			return nil, 0, nil
+		}
+
+		return parseProxyHeaderV1(data)
+	}
+
+	return nil, 0, ErrInvalidProxyHeader
+}
+
+// PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func parseProxyHeaderV1(data []byte) (net.Addr, int, error) {
+	var end = bytes.Index(data, []byte("\r\n"))
+	if end < 0 {
+		if len(data) >= proxyV1MaxLength {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+
+		return nil, 0, nil
+	}
+
+	var size = end + 2
+	if size > proxyV1MaxLength {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	var fields = strings.Split(string(data[:end]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, size, nil
+	}
+
+	if len(fields) != 6 || fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	var ip = net.ParseIP(fields[2])
+	var port, err = strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, size, nil
+}
+
+func parseProxyHeaderV2(data []byte) (net.Addr, int, error) {
+	var versionCommand = data[12]
+	if versionCommand>>4 != 0x02 {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	var family = data[13]
+	var addrLength = int(binary.BigEndian.Uint16(data[14:16]))
+	var size = proxyV2HeadLength + addrLength
+	if size > proxyV2MaxLength {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	if len(data) < size {
+		return nil, 0, nil
+	}
+
+	switch versionCommand & 0x0F {
+	case proxyV2CmdLocal:
+		return nil, size, nil
+	case proxyV2CmdProxy:
+	default:
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	var body = data[proxyV2HeadLength:size]
+	switch family {
+	case proxyV2FamilyTcp4:
+		if addrLength < proxyV2AddrLength4 {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+
+		var ip = net.IP(append([]byte(nil), body[0:4]...))
+		var port = binary.BigEndian.Uint16(body[8:10])
+		return &net.TCPAddr{IP: ip, Port: int(port)}, size, nil
+	case proxyV2FamilyTcp6:
+		if addrLength < proxyV2AddrLength6 {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+
+		var ip = net.IP(append([]byte(nil), body[0:16]...))
+		var port = binary.BigEndian.Uint16(body[32:34])
+		return &net.TCPAddr{IP: ip, Port: int(port)}, size, nil
+	default:
+		// UDP, unix socket等不关心的协议族, 沿用链接本身的地址
+		return nil, size, nil
+	}
+}
+
+// isPrefixOf data与signature的公共部分完全相同, 即data是signature的前缀或者signature是data的前缀
+func isPrefixOf(data []byte, signature []byte) bool {
+	var n = len(data)
+	if n > len(signature) {
+		n = len(signature)
+	}
+
+	return n > 0 && bytes.Equal(data[:n], signature[:n])
+}
@@ -0,0 +1,113 @@
+package epoll
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func buildProxyHeaderV2(command byte, family byte, body []byte) []byte {
+	var data = append([]byte(nil), proxyV2Signature...)
+	data = append(data, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(data[14:16], uint16(len(body)))
+	return append(data, body...)
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	var tcp4 = buildProxyHeaderV2(proxyV2CmdProxy, proxyV2FamilyTcp4, []byte{1, 2, 3, 4, 5, 6, 7, 8, 0x1f, 0x90, 0x01, 0xbb})
+	var tcp6Body = make([]byte, proxyV2AddrLength6)
+	tcp6Body[15] = 1
+	binary.BigEndian.PutUint16(tcp6Body[32:34], 8080)
+	var tcp6 = buildProxyHeaderV2(proxyV2CmdProxy, proxyV2FamilyTcp6, tcp6Body)
+	var local = buildProxyHeaderV2(proxyV2CmdLocal, 0, nil)
+	var oversized = buildProxyHeaderV2(proxyV2CmdProxy, proxyV2FamilyTcp4, make([]byte, proxyV2MaxLength))
+	var shortTcp4 = buildProxyHeaderV2(proxyV2CmdProxy, proxyV2FamilyTcp4, make([]byte, 4))
+
+	var cases = []struct {
+		name    string
+		data    []byte
+		addr    string
+		size    int
+		invalid bool
+	}{
+		{name: "v1 tcp4", data: []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nhello"), addr: "192.168.0.1:56324", size: 47},
+		{name: "v1 tcp6", data: []byte("PROXY TCP6 ::1 ::2 1000 443\r\n"), addr: "[::1]:1000", size: 29},
+		{name: "v1 unknown", data: []byte("PROXY UNKNOWN\r\n"), size: 15},
+		{name: "v1 truncated signature", data: []byte("PRO")},
+		{name: "v1 truncated line", data: []byte("PROXY TCP4 192.168.0.1")},
+		{name: "v1 oversized", data: []byte("PROXY TCP4 " + strings.Repeat("1", proxyV1MaxLength)), invalid: true},
+		{name: "v1 bad ip", data: []byte("PROXY TCP4 abc 192.168.0.11 56324 443\r\n"), invalid: true},
+		{name: "v1 bad port", data: []byte("PROXY TCP4 192.168.0.1 192.168.0.11 99999 443\r\n"), invalid: true},
+		{name: "v1 missing fields", data: []byte("PROXY TCP4 192.168.0.1\r\n"), invalid: true},
+		{name: "v2 tcp4", data: tcp4, addr: "1.2.3.4:8080", size: len(tcp4)},
+		{name: "v2 tcp6", data: tcp6, addr: "[::1]:8080", size: len(tcp6)},
+		{name: "v2 local", data: local, size: len(local)},
+		{name: "v2 truncated head", data: tcp4[:10]},
+		{name: "v2 truncated body", data: tcp4[:len(tcp4)-1]},
+		{name: "v2 oversized", data: oversized, invalid: true},
+		{name: "v2 short address", data: shortTcp4, invalid: true},
+		{name: "v2 bad version", data: append(append([]byte(nil), tcp4[:12]...), append([]byte{0x11}, tcp4[13:]...)...), invalid: true},
+		{name: "not a proxy header", data: []byte("GET / HTTP/1.1\r\n"), invalid: true},
+	}
+
+	for _, item := range cases {
+		var addr, size, err = parseProxyHeader(item.data)
+		if item.invalid {
+			if err != ErrInvalidProxyHeader {
+				t.Errorf("%s: expect ErrInvalidProxyHeader, got err=%v size=%d", item.name, err, size)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected err=%v", item.name, err)
+			continue
+		}
+
+		if size != item.size {
+			t.Errorf("%s: size=%d, expect %d", item.name, size, item.size)
+		}
+
+		var text = ""
+		if addr != nil {
+			text = addr.String()
+		}
+
+		if text != item.addr {
+			t.Errorf("%s: addr=%q, expect %q", item.name, text, item.addr)
+		}
+	}
+}
+
+func TestResolveProxyAddr(t *testing.T) {
+	var client = &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1000}
+	var proxy = &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2000}
+	var stranger = &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 3000}
+
+	var cases = []struct {
+		name    string
+		trusted trustedProxies
+		peer    net.Addr
+		expect  net.Addr
+	}{
+		{name: "trusted peer", trusted: parseTrustedProxies([]string{"10.0.0.0/8"}), peer: proxy, expect: client},
+		{name: "untrusted peer", trusted: parseTrustedProxies([]string{"10.0.0.0/8"}), peer: stranger},
+		{name: "empty list trusts none", trusted: nil, peer: proxy},
+		{name: "single ip", trusted: parseTrustedProxies([]string{"10.0.0.5"}), peer: proxy, expect: client},
+	}
+
+	for _, item := range cases {
+		var addr = item.trusted.resolveProxyAddr(item.peer, client)
+		if addr != item.expect {
+			t.Errorf("%s: addr=%v, expect %v", item.name, addr, item.expect)
+		}
+	}
+}
@@ -23,22 +23,27 @@ func NewTcpAcceptor(address string, opts ...AcceptorOption) *TcpAcceptor {
 		ConnChanSize:     16,
 		ReceivedChanSize: 16,
 		PollBufferSize:   1024,
+		WatcherCount:     1,
 	}
 
 	for _, opt := range opts {
 		opt(&options)
 	}
 
+	if options.ProxyProtocol && len(options.TrustedProxies) == 0 {
+		logo.Warn("PROXY protocol is enabled without trusted proxies, the addresses in PROXY headers will be ignored, see WithTrustedProxies()")
+	}
+
 	var my = &TcpAcceptor{
-		PlayerAcceptor: newPlayerAcceptor(),
+		PlayerAcceptor: newPlayerAcceptor(options),
 		connChan:       make(chan PlayerConn, options.ConnChanSize),
 	}
 
-	go my.goListener(address, options.ReceivedChanSize)
+	go my.goListener(address, options)
 	return my
 }
 
-func (my *TcpAcceptor) goListener(address string, receivedChanSize int) {
+func (my *TcpAcceptor) goListener(address string, options acceptorOptions) {
 	defer loom.DumpIfPanic()
 
 	listener, err := net.Listen("tcp", address)
@@ -48,7 +53,6 @@ func (my *TcpAcceptor) goListener(address string, receivedChanSize int) {
 	}
 	defer listener.Close()
 
-	var watcher = my.getWatcher()
 	for !my.IsClosed() {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -56,8 +60,14 @@ func (my *TcpAcceptor) goListener(address string, receivedChanSize int) {
 			continue
 		}
 
-		var connection = newTcpConn(conn, watcher, receivedChanSize)
+		var watcher = my.getWatcher(conn)
+		var connection = newTcpConn(conn, watcher, options.ReceivedChanSize)
 		if connection != nil {
+			connection.writer.setLimits(options.WriteDeadline, options.MaxPendingWriteBytes)
+			if options.ProxyProtocol {
+				connection.enableProxyProtocol(options.TrustedProxies)
+			}
+
 			var err = watcher.Read(connection, conn, nil)
 			if err == nil {
 				my.connChan <- connection
@@ -69,3 +79,7 @@ func (my *TcpAcceptor) goListener(address string, receivedChanSize int) {
 func (my *TcpAcceptor) GetConnChan() chan PlayerConn {
 	return my.connChan
 }
+
+func (my *TcpAcceptor) Kind() string {
+	return KindTcp
+}
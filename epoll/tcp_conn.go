@@ -2,9 +2,10 @@ package epoll
 
 import (
 	"github.com/lixianmin/got/loom"
-	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/util/bufferpool"
 	"github.com/xtaci/gaio"
 	"net"
+	"sync/atomic"
 )
 
 /********************************************************************
@@ -19,7 +20,12 @@ type TcpConn struct {
 	watcher      *gaio.Watcher
 	receivedChan chan Message
 	input        *Buffer
+	writer       writeTracker
 	wc           loom.WaitClose
+
+	isWaitingProxyHeader bool           // 开启PROXY protocol后, 在收到header之前不处理pomelo帧
+	trustedProxies       trustedProxies // 只采纳来自受信任代理的PROXY header, 为空时一律不采纳
+	remoteAddr           atomic.Value   // PROXY header中解析出来的client真实地址
 }
 
 func newTcpConn(conn net.Conn, watcher *gaio.Watcher, receivedChanSize int) *TcpConn {
@@ -42,6 +48,11 @@ func (my *TcpConn) GetReceivedChan() <-chan Message {
 	return my.receivedChan
 }
 
+func (my *TcpConn) enableProxyProtocol(trusted trustedProxies) {
+	my.isWaitingProxyHeader = true
+	my.trustedProxies = trusted
+}
+
 func (my *TcpConn) onReceiveData(buff []byte) error {
 	var input = my.input
 	var _, err = input.Write(buff)
@@ -49,38 +60,63 @@ func (my *TcpConn) onReceiveData(buff []byte) error {
 		return err
 	}
 
-	var headLength = codec.HeadLength
-	var data = input.Bytes()
-
-	for len(data) > headLength {
-		var header = data[:headLength]
-		msgSize, _, err := codec.ParseHeader(header)
+	if my.isWaitingProxyHeader {
+		addr, size, err := parseProxyHeader(input.Bytes())
 		if err != nil {
 			return err
 		}
 
-		var totalSize = headLength + msgSize
-		if len(data) < totalSize {
+		// header还不完整, 等待后续数据
+		if size == 0 {
 			return nil
 		}
 
-		var frameData = make([]byte, totalSize)
-		copy(frameData, data[:totalSize])
+		my.isWaitingProxyHeader = false
+		input.Next(size)
 
-		my.writeMessage(Message{Data: frameData})
-		input.Next(totalSize)
-		data = input.Bytes()
+		if addr = my.trustedProxies.resolveProxyAddr(my.conn.RemoteAddr(), addr); addr != nil {
+			my.remoteAddr.Store(addr)
+		}
 	}
 
-	my.input.Tidy()
-	return nil
+	return fetchFrames(input, my.writeMessage)
 }
 
 // Write writes data to the connection.
 // Write can be made to time out and return an Error with Timeout() == true
 // after a fixed time limit; see SetDeadline and SetWriteDeadline.
+//
+// gaio是异步写的, 在OpWrite完成之前会一直引用buffer, 因此这里copy一份池化的buffer交给watcher,
+// 调用方在Write()返回后就可以复用b, 池化的buffer在onWriteCompleted()中归还
 func (my *TcpConn) Write(b []byte) (int, error) {
-	return len(b), my.watcher.Write(my, my.conn, b)
+	var buff = bufferpool.Get(len(b))
+	copy(buff, b)
+
+	if err := my.WritePooled(buff); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// WritePooled 直接把池化的buff交给watcher, 不再copy. sessionSender合并出来的buffer走这条路径
+func (my *TcpConn) WritePooled(buff []byte) error {
+	if err := my.writer.write(my.watcher, my, my.conn, buff); err != nil {
+		bufferpool.Put(buff)
+		return err
+	}
+
+	return nil
+}
+
+func (my *TcpConn) onWriteCompleted(buff []byte) {
+	my.writer.onWriteCompleted(buff)
+	bufferpool.Put(buff)
+}
+
+// PendingWriteBytes 已经交给watcher但还没有写完的字节数
+func (my *TcpConn) PendingWriteBytes() int64 {
+	return my.writer.getPendingBytes()
 }
 
 func (my *TcpConn) writeMessage(msg Message) {
@@ -103,7 +139,11 @@ func (my *TcpConn) LocalAddr() net.Addr {
 	return my.conn.LocalAddr()
 }
 
-// RemoteAddr returns the remote address.
+// RemoteAddr returns the remote address, which is the real client address if the PROXY protocol is enabled.
 func (my *TcpConn) RemoteAddr() net.Addr {
+	if addr, ok := my.remoteAddr.Load().(net.Addr); ok {
+		return addr
+	}
+
 	return my.conn.RemoteAddr()
 }
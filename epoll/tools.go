@@ -4,6 +4,7 @@ import (
 	"github.com/lixianmin/road/conn/codec"
 	"github.com/lixianmin/road/conn/packet"
 	"github.com/lixianmin/road/ifs"
+	"github.com/lixianmin/road/util/bufferpool"
 )
 
 /********************************************************************
@@ -33,3 +34,33 @@ func checkReceivedMsgBytes(msgBytes []byte) error {
 
 	return nil
 }
+
+// fetchFrames 从input中切出所有完整的pomelo帧交给handler, 不完整的半帧数据留在input中等待后续数据.
+// 每一帧都copy到bufferpool的buffer中, 以Message的形式投递给session, 由session处理完之后Release()
+func fetchFrames(input *Buffer, handler func(msg Message)) error {
+	var headLength = codec.HeadLength
+	var data = input.Bytes()
+
+	for len(data) > headLength {
+		var header = data[:headLength]
+		msgSize, _, err := codec.ParseHeader(header)
+		if err != nil {
+			return err
+		}
+
+		var totalSize = headLength + msgSize
+		if len(data) < totalSize {
+			return nil
+		}
+
+		var frameData = bufferpool.Get(totalSize)
+		copy(frameData, data[:totalSize])
+
+		handler(Message{Data: frameData, isPooled: true})
+		input.Next(totalSize)
+		data = input.Bytes()
+	}
+
+	input.Tidy()
+	return nil
+}
@@ -0,0 +1,117 @@
+package epoll
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies 同时支持CIDR与单个IP, 无法解析的条目直接忽略
+func parseTrustedProxies(items []string) trustedProxies {
+	var list trustedProxies
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if _, ipNet, err := net.ParseCIDR(item); err == nil {
+			list = append(list, ipNet)
+			continue
+		}
+
+		if ip := net.ParseIP(item); ip != nil {
+			var bits = 8 * len(ip)
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+
+			list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+
+	return list
+}
+
+func (my trustedProxies) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range my {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (my trustedProxies) containsAddr(addr net.Addr) bool {
+	return my.contains(addrToIP(addr))
+}
+
+// resolveProxyAddr 只有直连的peer是受信任的代理时, 才会采纳PROXY header中的地址, 否则返回nil
+func (my trustedProxies) resolveProxyAddr(peer net.Addr, addr net.Addr) net.Addr {
+	if addr == nil || !my.containsAddr(peer) {
+		return nil
+	}
+
+	return addr
+}
+
+// resolveForwardedAddr 只有直连的peer是受信任的代理时, 才会采纳X-Forwarded-For与X-Real-IP.
+// X-Forwarded-For从右往左找第一个不受信任的地址, 因为左侧的内容是client自己可以伪造的
+func (my trustedProxies) resolveForwardedAddr(peer net.Addr, header http.Header) net.Addr {
+	if !my.containsAddr(peer) {
+		return peer
+	}
+
+	var port = 0
+	if tcpAddr, ok := peer.(*net.TCPAddr); ok {
+		port = tcpAddr.Port
+	}
+
+	if forwarded := header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		var items = strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(items) - 1; i >= 0; i-- {
+			var ip = net.ParseIP(strings.TrimSpace(items[i]))
+			if ip == nil {
+				break
+			}
+
+			if i == 0 || !my.contains(ip) {
+				return &net.TCPAddr{IP: ip, Port: port}
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(header.Get("X-Real-IP"))); ip != nil {
+		return &net.TCPAddr{IP: ip, Port: port}
+	}
+
+	return peer
+}
+
+func addrToIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case nil:
+		return nil
+	default:
+		var host, _, err = net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil
+		}
+
+		return net.ParseIP(host)
+	}
+}
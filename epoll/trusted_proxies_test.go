@@ -0,0 +1,59 @@
+package epoll
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestResolveForwardedAddr(t *testing.T) {
+	var trusted = parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	var proxy = &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 2000}
+	var stranger = &net.TCPAddr{IP: net.ParseIP("8.8.8.8"), Port: 3000}
+
+	var cases = []struct {
+		name      string
+		peer      net.Addr
+		forwarded []string
+		realIp    string
+		expect    string
+	}{
+		{name: "untrusted forwarded", peer: stranger, forwarded: []string{"1.1.1.1"}, expect: "8.8.8.8:3000"},
+		{name: "untrusted real ip", peer: stranger, realIp: "1.1.1.1", expect: "8.8.8.8:3000"},
+		{name: "trusted forwarded", peer: proxy, forwarded: []string{"1.1.1.1"}, expect: "1.1.1.1:2000"},
+		{name: "trusted real ip", peer: proxy, realIp: "1.1.1.1", expect: "1.1.1.1:2000"},
+		{name: "forwarded before real ip", peer: proxy, forwarded: []string{"1.1.1.1"}, realIp: "2.2.2.2", expect: "1.1.1.1:2000"},
+		{name: "rightmost untrusted wins", peer: proxy, forwarded: []string{"6.6.6.6, 1.1.1.1, 10.0.0.6, 192.168.1.1"}, expect: "1.1.1.1:2000"},
+		{name: "multiple header lines", peer: proxy, forwarded: []string{"6.6.6.6", "1.1.1.1, 10.0.0.6"}, expect: "1.1.1.1:2000"},
+		{name: "all trusted", peer: proxy, forwarded: []string{"10.0.0.7, 10.0.0.6"}, expect: "10.0.0.7:2000"},
+		{name: "ipv6", peer: proxy, forwarded: []string{"::1"}, expect: "[::1]:2000"},
+		{name: "malformed rightmost", peer: proxy, forwarded: []string{"1.1.1.1, garbage"}, expect: "10.0.0.5:2000"},
+		{name: "malformed in chain", peer: proxy, forwarded: []string{"1.1.1.1, bad, 10.0.0.6"}, realIp: "2.2.2.2", expect: "2.2.2.2:2000"},
+		{name: "with port", peer: proxy, forwarded: []string{"1.1.1.1:80"}, expect: "10.0.0.5:2000"},
+		{name: "malformed real ip", peer: proxy, realIp: "not an ip", expect: "10.0.0.5:2000"},
+		{name: "no headers", peer: proxy, expect: "10.0.0.5:2000"},
+	}
+
+	for _, item := range cases {
+		var header = http.Header{}
+		for _, value := range item.forwarded {
+			header.Add("X-Forwarded-For", value)
+		}
+
+		if item.realIp != "" {
+			header.Set("X-Real-IP", item.realIp)
+		}
+
+		var addr = trusted.resolveForwardedAddr(item.peer, header)
+		if addr.String() != item.expect {
+			t.Errorf("%s: addr=%s, expect %s", item.name, addr, item.expect)
+		}
+	}
+}
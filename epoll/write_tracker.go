@@ -0,0 +1,70 @@
+package epoll
+
+import (
+	"errors"
+	"github.com/xtaci/gaio"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+gaio的Write()只是把buffer挂到watcher上就返回了, 对端不读数据时, 数据会一直积压在进程里.
+writeTracker通过OpWrite的完成事件统计还没有写完的字节数, 并为每次写入设置deadline:
+1. 积压的字节数超过上限时, Write()直接返回ErrTooManyPendingBytes
+2. 超过deadline还没有写完时, gaio会返回gaio.ErrDeadline, 由goWatcher()通过sendErrorMessage()通知session关闭链接
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+var ErrTooManyPendingBytes = errors.New("too many pending bytes waiting to be written")
+
+// writeCompleter 作为gaio写操作context的链接, 在OpWrite完成(或者失败)时被goWatcher()回调
+type writeCompleter interface {
+	onWriteCompleted(buff []byte)
+}
+
+type writeTracker struct {
+	pendingBytes    int64
+	maxPendingBytes int64         // 为0时不限制
+	deadline        time.Duration // 为0时不设置deadline
+}
+
+func (my *writeTracker) setLimits(deadline time.Duration, maxPendingBytes int) {
+	my.deadline = deadline
+	my.maxPendingBytes = int64(maxPendingBytes)
+}
+
+func (my *writeTracker) write(watcher *gaio.Watcher, ctx interface{}, conn net.Conn, buff []byte) error {
+	var size = int64(len(buff))
+	var pending = atomic.AddInt64(&my.pendingBytes, size)
+	if my.maxPendingBytes > 0 && pending > my.maxPendingBytes {
+		atomic.AddInt64(&my.pendingBytes, -size)
+		return ErrTooManyPendingBytes
+	}
+
+	var err error
+	if my.deadline > 0 {
+		err = watcher.WriteTimeout(ctx, conn, buff, time.Now().Add(my.deadline))
+	} else {
+		err = watcher.Write(ctx, conn, buff)
+	}
+
+	if err != nil {
+		atomic.AddInt64(&my.pendingBytes, -size)
+	}
+
+	return err
+}
+
+// onWriteCompleted 无论写成功还是失败, 这个buffer都不再占用积压的字节数
+func (my *writeTracker) onWriteCompleted(buff []byte) {
+	atomic.AddInt64(&my.pendingBytes, -int64(len(buff)))
+}
+
+func (my *writeTracker) getPendingBytes() int64 {
+	return atomic.LoadInt64(&my.pendingBytes)
+}
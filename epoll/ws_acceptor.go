@@ -2,6 +2,7 @@ package epoll
 
 import (
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"net/http"
 )
 
@@ -14,6 +15,7 @@ Copyright (C) - All Rights Reserved
 
 type WsAcceptor struct {
 	*PlayerAcceptor
+	options          acceptorOptions
 	connChan         chan PlayerConn
 	receivedChanSize int
 	isClosed         int32
@@ -24,6 +26,8 @@ func NewWsAcceptor(serveMux IServeMux, servePath string, opts ...AcceptorOption)
 		ConnChanSize:     16,
 		ReceivedChanSize: 16,
 		PollBufferSize:   1024,
+		WatcherCount:     1,
+		DeflateThreshold: 256,
 	}
 
 	for _, opt := range opts {
@@ -31,7 +35,8 @@ func NewWsAcceptor(serveMux IServeMux, servePath string, opts ...AcceptorOption)
 	}
 
 	var my = &WsAcceptor{
-		PlayerAcceptor:   newPlayerAcceptor(),
+		PlayerAcceptor:   newPlayerAcceptor(options),
+		options:          options,
 		connChan:         make(chan PlayerConn, options.ConnChanSize),
 		receivedChanSize: options.ReceivedChanSize,
 	}
@@ -41,19 +46,63 @@ func NewWsAcceptor(serveMux IServeMux, servePath string, opts ...AcceptorOption)
 }
 
 func (my *WsAcceptor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(my.options.AllowedOrigins, r) {
+		http.Error(w, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+
+	var data = newUpgradeData(r)
+	if my.options.UpgradeHook != nil {
+		var value, err = my.options.UpgradeHook(r)
+		if err != nil {
+			writeUpgradeRejection(w, err)
+			return
+		}
+
+		data.Value = value
+	}
+
 	// Upgrade connection
-	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	var upgrader = ws.HTTPUpgrader{Protocol: my.options.SelectProtocol}
+	var extension *wsflate.Extension
+	if my.options.Deflate {
+		extension = &wsflate.Extension{
+			Parameters: wsflate.Parameters{
+				ServerNoContextTakeover: my.options.DeflateServerNoContextTakeover,
+				ClientNoContextTakeover: my.options.DeflateClientNoContextTakeover,
+			},
+		}
+		upgrader.Negotiate = extension.Negotiate
+	}
+
+	conn, _, hs, err := upgrader.Upgrade(r, w)
 	if err != nil {
 		return
 	}
 
-	var watcher = my.getWatcher()
+	data.Protocol = hs.Protocol
+
+	var watcher = my.getWatcher(conn)
 	if watcher == nil {
 		return
 	}
 
-	var item = newWsConn(conn, watcher, my.receivedChanSize)
+	var remoteAddr = my.options.TrustedProxies.resolveForwardedAddr(conn.RemoteAddr(), r.Header)
+	var item = newWsConn(conn, remoteAddr, data, watcher, my.receivedChanSize)
 	if item != nil {
+		item.pingKeepalive = my.options.PingKeepalive
+		item.jsonMode = my.options.JsonTextMode
+		item.jsonHandshake = my.options.JsonHandshake
+		item.writer.setLimits(my.options.WriteDeadline, my.options.MaxPendingWriteBytes)
+		// Accepted()返回的是client的offer, server的回复则是extension.Parameters, 任何一方声明了no_context_takeover都要遵守
+		if extension != nil {
+			if offer, accepted := extension.Accepted(); accepted {
+				var serverNoContextTakeover = offer.ServerNoContextTakeover || extension.Parameters.ServerNoContextTakeover
+				var clientNoContextTakeover = offer.ClientNoContextTakeover || extension.Parameters.ClientNoContextTakeover
+				item.enableDeflate(newWsDeflate(my.options.DeflateThreshold, serverNoContextTakeover, clientNoContextTakeover))
+			}
+		}
+
 		var err = watcher.Read(item, conn, nil)
 		if err == nil {
 			my.connChan <- item
@@ -64,3 +113,7 @@ func (my *WsAcceptor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (my *WsAcceptor) GetConnChan() chan PlayerConn {
 	return my.connChan
 }
+
+func (my *WsAcceptor) Kind() string {
+	return KindWs
+}
@@ -1,13 +1,15 @@
 package epoll
 
 import (
+	"fmt"
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/lixianmin/got/loom"
 	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/util/bufferpool"
 	"github.com/xtaci/gaio"
 	"io"
 	"net"
+	"sync/atomic"
 )
 
 /********************************************************************
@@ -17,26 +19,64 @@ author:     lixianmin
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
-type WsConn struct {
-	conn         net.Conn
-	watcher      *gaio.Watcher
-	receivedChan chan Message
-	readerWriter *WsReaderWriter
-	wc           loom.WaitClose
-}
+// wsMaxMessageSize 一条websocket消息(所有分片合计)的最大长度
+const wsMaxMessageSize = codec.HeadLength + codec.MaxPacketSize
+
+type (
+	WsConn struct {
+		conn          net.Conn
+		remoteAddr    net.Addr
+		upgradeData   *UpgradeData
+		watcher       *gaio.Watcher
+		receivedChan  chan Message
+		readerWriter  *WsReaderWriter
+		writer        writeTracker
+		pingKeepalive bool
+		jsonMode      bool       // 是否支持text帧的json调试模式
+		jsonHandshake bool       // json模式的client是否必须显式发送handshake
+		isJsonActive  int32      // 收到第一个text帧之后, 这个链接的所有输出都转换为json文本帧
+		deflate       *wsDeflate // 协商了permessage-deflate时不为nil
+		wc            loom.WaitClose
+
+		// 下面这组字段只在watcher的goroutine中访问
+		state        ws.State  // 记录是否处于分片(fragmented)状态
+		fragments    []byte    // 分片消息在收齐之前暂存在这里
+		isCompressed bool      // 当前消息是否压缩, 由消息第一帧的rsv1决定
+		messageOp    ws.OpCode // 当前消息的类型(text或binary), 由消息第一帧决定
+		isClosing    bool      // 收到client的close帧之后, 不再处理后续数据
+	}
 
-func newWsConn(conn net.Conn, watcher *gaio.Watcher, receivedChanSize int) *WsConn {
+	// WsCloseError client发来close帧时, session以它作为关闭的原因
+	WsCloseError struct {
+		Code   int
+		Reason string
+	}
+)
+
+func newWsConn(conn net.Conn, remoteAddr net.Addr, upgradeData *UpgradeData, watcher *gaio.Watcher, receivedChanSize int) *WsConn {
 	var receivedChan = make(chan Message, receivedChanSize)
 	var my = &WsConn{
 		conn:         conn,
+		remoteAddr:   remoteAddr,
+		upgradeData:  upgradeData,
 		watcher:      watcher,
 		receivedChan: receivedChan,
 		readerWriter: NewWsReaderWriter(conn, watcher),
+		state:        ws.StateServerSide,
 	}
 
 	return my
 }
 
+func (my *WsConn) enableDeflate(deflate *wsDeflate) {
+	my.deflate = deflate
+	my.state = my.state.Set(ws.StateExtended)
+}
+
+func (err *WsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed by peer, code=%d reason=%q", err.Code, err.Reason)
+}
+
 func (my *WsConn) sendErrorMessage(err error) {
 	if err != nil {
 		my.writeMessage(Message{Err: err})
@@ -51,12 +91,12 @@ func (my *WsConn) onReceiveData(buff []byte) error {
 	var input = my.readerWriter.input
 	_, _ = input.Write(buff)
 
-	for input.Len() > codec.HeadLength {
-		var lastOffsetSet = input.GetOffset()
-		data, _, err := wsutil.ReadData(my.readerWriter, ws.StateServerSide)
+	for input.Len() > 0 && !my.isClosing {
+		var lastOffset = input.GetOffset()
+		header, err := ws.ReadHeader(my.readerWriter)
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				input.SetOffset(lastOffsetSet)
+				input.SetOffset(lastOffset)
 				return nil
 			}
 
@@ -64,12 +104,32 @@ func (my *WsConn) onReceiveData(buff []byte) error {
 			return err
 		}
 
-		if err := checkReceivedMsgBytes(data); err != nil {
-			input.SetOffset(lastOffsetSet)
+		if err := my.checkHeader(header); err != nil {
+			my.writeMessage(Message{Err: err})
+			return err
+		}
+
+		if header.Length > wsMaxMessageSize {
+			var err = my.rejectTooBig()
+			my.writeMessage(Message{Err: err})
+			return err
+		}
+
+		// payload还没有收齐, 等待后续数据
+		if int64(input.Len()) < header.Length {
+			input.SetOffset(lastOffset)
 			return nil
 		}
 
-		my.writeMessage(Message{Data: data})
+		var payload = input.Next(int(header.Length))
+		if header.Masked {
+			ws.Cipher(payload, header.Mask, 0)
+		}
+
+		if err := my.onReceiveFrame(header, payload); err != nil {
+			my.writeMessage(Message{Err: err})
+			return err
+		}
 	}
 
 	input.Tidy()
@@ -77,6 +137,145 @@ func (my *WsConn) onReceiveData(buff []byte) error {
 	return nil
 }
 
+func (my *WsConn) checkHeader(header ws.Header) error {
+	if err := ws.CheckHeader(header, my.state); err != nil {
+		return err
+	}
+
+	// permessage-deflate只使用rsv1, 并且控制帧不能压缩
+	if header.Rsv2() || header.Rsv3() || header.Rsv1() && header.OpCode.IsControl() {
+		return ws.ErrProtocolNonZeroRsv
+	}
+
+	return nil
+}
+
+// onReceiveFrame payload引用的是input的内存, 需要保留的数据必须copy出来
+func (my *WsConn) onReceiveFrame(header ws.Header, payload []byte) error {
+	switch header.OpCode {
+	case ws.OpPing:
+		// ping与pong都说明链接是通的, 投递一个keepalive的Message用于刷新session的心跳时间
+		my.writeMessage(Message{IsKeepalive: true})
+		return my.writeFrame(ws.NewPongFrame(payload))
+	case ws.OpPong:
+		my.writeMessage(Message{IsKeepalive: true})
+		return nil
+	case ws.OpClose:
+		// 回复close帧完成close握手, 然后由session在处理WsCloseError时关闭链接
+		var code, reason = ws.ParseCloseFrameData(payload)
+		my.isClosing = true
+		_ = my.writeFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(code, "")))
+		my.writeMessage(Message{Err: &WsCloseError{Code: int(code), Reason: reason}})
+		return nil
+	}
+
+	if header.OpCode != ws.OpContinuation {
+		my.isCompressed = header.Rsv1()
+		my.messageOp = header.OpCode
+	} else if header.Rsv1() {
+		return ws.ErrProtocolNonZeroRsv
+	}
+
+	// 最常见的单帧未压缩binary消息, 直接copy到池化的buffer中投递, 不经过fragments
+	if header.Fin && header.OpCode != ws.OpContinuation && !my.isCompressed && !(my.jsonMode && my.messageOp == ws.OpText) {
+		if len(payload) == 0 {
+			my.writeMessage(Message{})
+			return nil
+		}
+
+		if err := checkReceivedMsgBytes(payload); err != nil {
+			return err
+		}
+
+		var frameData = bufferpool.Get(len(payload))
+		copy(frameData, payload)
+		my.writeMessage(Message{Data: frameData, isPooled: true})
+		return nil
+	}
+
+	// 每一帧的长度都受限制, 但client可以发送任意多个非FIN的分片, 因此还要限制累计的长度
+	if len(my.fragments)+len(payload) > wsMaxMessageSize {
+		return my.rejectTooBig()
+	}
+
+	my.fragments = append(my.fragments, payload...)
+	if !header.Fin {
+		my.state = my.state.Set(ws.StateFragmented)
+		return nil
+	}
+
+	var data = my.fragments
+	my.fragments = nil
+	my.state = my.state.Clear(ws.StateFragmented)
+
+	if my.isCompressed {
+		var err error
+		if data, err = my.deflate.decompress(data); err != nil {
+			return err
+		}
+	}
+
+	if my.jsonMode && my.messageOp == ws.OpText {
+		return my.onReceiveJson(data)
+	}
+
+	if len(data) == 0 {
+		my.writeMessage(Message{})
+		return nil
+	}
+
+	if err := checkReceivedMsgBytes(data); err != nil {
+		return err
+	}
+
+	my.writeMessage(Message{Data: data})
+	return nil
+}
+
+// rejectTooBig 消息超长时用1009(message too big)完成close握手, 然后由session关闭链接
+func (my *WsConn) rejectTooBig() error {
+	my.isClosing = true
+	my.fragments = nil
+	_ = my.WriteClose(int(ws.StatusMessageTooBig), "message too big")
+	return codec.ErrPacketSizeExcced
+}
+
+func (my *WsConn) onReceiveJson(data []byte) error {
+	var envelope, err = decodeJsonEnvelope(data)
+	if err != nil {
+		return err
+	}
+
+	var isHandshake = envelope.Type == jsonTypeHandshake
+	if atomic.LoadInt32(&my.isJsonActive) == 0 {
+		if my.jsonHandshake && !isHandshake {
+			return ErrJsonHandshakeRequired
+		}
+
+		// 没有要求显式握手时, 第一个text帧到达就替client补上handshake
+		handshake, err := encodeJsonHandshake(envelope.Body)
+		if err != nil {
+			return err
+		}
+
+		atomic.StoreInt32(&my.isJsonActive, 1)
+		my.writeMessage(Message{Data: handshake})
+	}
+
+	// 重复的handshake直接忽略
+	if isHandshake {
+		return nil
+	}
+
+	frameData, err := encodeJsonRequest(envelope)
+	if err != nil {
+		return err
+	}
+
+	my.writeMessage(Message{Data: frameData})
+	return nil
+}
+
 func (my *WsConn) writeMessage(msg Message) {
 	select {
 	case my.receivedChan <- msg:
@@ -84,12 +283,35 @@ func (my *WsConn) writeMessage(msg Message) {
 	}
 }
 
+// writeFrame 将整个帧编码后一次写入, 防止不同goroutine写入的帧相互交错
+func (my *WsConn) writeFrame(frame ws.Frame) error {
+	var data, err = ws.CompileFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	// 以WsConn作为context, 写失败或者超过deadline时goWatcher()可以通过sendErrorMessage()通知session
+	return my.writer.write(my.watcher, my, my.conn, data)
+}
+
+func (my *WsConn) onWriteCompleted(buff []byte) {
+	my.writer.onWriteCompleted(buff)
+}
+
+// PendingWriteBytes 已经交给watcher但还没有写完的字节数
+func (my *WsConn) PendingWriteBytes() int64 {
+	return my.writer.getPendingBytes()
+}
+
 // Write writes data to the connection.
 // Write can be made to time out and return an Error with Timeout() == true
 // after a fixed time limit; see SetDeadline and SetWriteDeadline.
 func (my *WsConn) Write(b []byte) (int, error) {
-	var frame = ws.NewBinaryFrame(b)
-	var err = ws.WriteFrame(my.readerWriter, frame)
+	if atomic.LoadInt32(&my.isJsonActive) == 1 {
+		return my.writeJson(b)
+	}
+
+	var err = my.writeData(ws.OpBinary, b)
 	if err != nil {
 		return 0, err
 	}
@@ -97,6 +319,57 @@ func (my *WsConn) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+func (my *WsConn) writeJson(b []byte) (int, error) {
+	var frames, isHeartbeat, err = encodeJsonFrames(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// json模式下用ping帧代替pomelo的心跳包, 浏览器会自动回复pong
+	if isHeartbeat {
+		if err := my.WritePing(); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, frame := range frames {
+		if err := my.writeData(ws.OpText, frame); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func (my *WsConn) writeData(op ws.OpCode, data []byte) error {
+	var frame = ws.NewFrame(op, true, data)
+	if my.deflate != nil && my.deflate.needCompress(data) {
+		var compressed, err = my.deflate.compress(data)
+		if err != nil {
+			return err
+		}
+
+		frame = ws.NewFrame(op, true, compressed)
+		frame.Header.Rsv = ws.Rsv(true, false, false)
+	}
+
+	return my.writeFrame(frame)
+}
+
+// WriteClose 发送close帧, 发起close握手
+func (my *WsConn) WriteClose(code int, reason string) error {
+	return my.writeFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason)))
+}
+
+// PingKeepalive 是否使用websocket协议层的ping/pong代替pomelo的heartbeat
+func (my *WsConn) PingKeepalive() bool {
+	return my.pingKeepalive
+}
+
+func (my *WsConn) WritePing() error {
+	return my.writeFrame(ws.NewPingFrame(nil))
+}
+
 // Close closes the connection.
 // Any blocked Read or Write operations will be unblocked and return errors.
 func (my *WsConn) Close() error {
@@ -110,7 +383,12 @@ func (my *WsConn) LocalAddr() net.Addr {
 	return my.conn.LocalAddr()
 }
 
-// RemoteAddr returns the remote address.
+// RemoteAddr returns the remote address, X-Forwarded-For and X-Real-IP are used if the peer is a trusted proxy.
 func (my *WsConn) RemoteAddr() net.Addr {
-	return my.conn.RemoteAddr()
+	return my.remoteAddr
+}
+
+// UpgradeData websocket升级请求中的headers, query, cookies等数据
+func (my *WsConn) UpgradeData() *UpgradeData {
+	return my.upgradeData
 }
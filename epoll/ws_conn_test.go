@@ -0,0 +1,127 @@
+package epoll
+
+import (
+	"context"
+	"github.com/gobwas/ws"
+	"github.com/lixianmin/road/conn/codec"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// dialTestWs 启动一个WsAcceptor并建立链接, 返回client端的链接与server端的WsConn
+func dialTestWs(t *testing.T, dialer ws.Dialer, opts ...AcceptorOption) (net.Conn, *WsConn) {
+	var mux = http.NewServeMux()
+	var accept = NewWsAcceptor(mux, "/ws", opts...)
+	var server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var url = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	var conn, _, _, err = dialer.Dial(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	select {
+	case item := <-accept.GetConnChan():
+		var wsConn = item.(*WsConn)
+		t.Cleanup(func() { _ = wsConn.Close() })
+		return conn, wsConn
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for server conn")
+		return nil, nil
+	}
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, frame ws.Frame) {
+	if err := ws.WriteFrame(conn, ws.MaskFrameInPlace(frame)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receiveServerMessage(t *testing.T, wsConn *WsConn) Message {
+	select {
+	case msg := <-wsConn.GetReceivedChan():
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for message")
+		return Message{}
+	}
+}
+
+func TestWsEmptyBinaryFrame(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{})
+	writeClientFrame(t, conn, ws.NewBinaryFrame(nil))
+
+	var msg = receiveServerMessage(t, wsConn)
+	if msg.Err != nil || msg.IsKeepalive || len(msg.Data) != 0 {
+		t.Fatalf("expect an empty message, got data=%v err=%v keepalive=%v", msg.Data, msg.Err, msg.IsKeepalive)
+	}
+}
+
+func TestWsPingKeepalive(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{})
+	writeClientFrame(t, conn, ws.NewPingFrame([]byte("ping")))
+
+	var msg = receiveServerMessage(t, wsConn)
+	if !msg.IsKeepalive || msg.Data != nil || msg.Err != nil {
+		t.Fatalf("expect a keepalive message, got data=%v err=%v keepalive=%v", msg.Data, msg.Err, msg.IsKeepalive)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var frame, err = ws.ReadFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Header.OpCode != ws.OpPong || string(frame.Payload) != "ping" {
+		t.Fatalf("expect a pong frame, got op=%v payload=%q", frame.Header.OpCode, frame.Payload)
+	}
+}
+
+func TestWsFragmentsLimit(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{})
+
+	// 每一帧都没有超过上限, 但累计的长度超过了
+	var chunk = make([]byte, 1<<20)
+	var count = wsMaxMessageSize/len(chunk) + 2
+	go func() {
+		for i := 0; i < count; i++ {
+			var op = ws.OpContinuation
+			if i == 0 {
+				op = ws.OpBinary
+			}
+
+			if err := ws.WriteFrame(conn, ws.MaskFrameInPlace(ws.NewFrame(op, false, chunk))); err != nil {
+				return
+			}
+		}
+	}()
+
+	var msg = receiveServerMessage(t, wsConn)
+	if msg.Err != codec.ErrPacketSizeExcced {
+		t.Fatalf("expect ErrPacketSizeExcced, got err=%v", msg.Err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var frame, err = ws.ReadFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var code, _ = ws.ParseCloseFrameData(frame.Payload)
+	if frame.Header.OpCode != ws.OpClose || code != ws.StatusMessageTooBig {
+		t.Fatalf("expect close frame with 1009, got op=%v code=%d", frame.Header.OpCode, code)
+	}
+}
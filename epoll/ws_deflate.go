@@ -0,0 +1,118 @@
+package epoll
+
+import (
+	"bytes"
+	"compress/flate"
+	"github.com/lixianmin/road/conn/codec"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+websocket的permessage-deflate压缩, 参考: https://datatracker.ietf.org/doc/html/rfc7692
+
+context takeover的含义是相邻消息之间共享LZ77的滑动窗口:
+1. 压缩方向: 同一个flate.Writer一直写下去, 每条消息Flush()一次即可
+2. 解压方向: flate.Reader在数据读完后会记录错误, 无法复用, 因此保存最近32KB的输出作为下一条消息的字典
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const deflateWindowSize = 32768
+
+var (
+	deflateTail     = []byte{0, 0, 0xff, 0xff}
+	deflateReadTail = []byte{0, 0, 0xff, 0xff, 1, 0, 0, 0xff, 0xff} // 追加一个final的空block, 让flate.Reader正常结束
+)
+
+type wsDeflate struct {
+	threshold               int  // 小于threshold字节的消息不压缩
+	serverNoContextTakeover bool // 为true时每条发出的消息独立压缩
+	clientNoContextTakeover bool // 为true时每条收到的消息独立解压
+
+	writeLock   sync.Mutex
+	writer      *flate.Writer
+	writeBuffer bytes.Buffer
+
+	reader   io.ReadCloser
+	readDict []byte
+}
+
+func newWsDeflate(threshold int, serverNoContextTakeover bool, clientNoContextTakeover bool) *wsDeflate {
+	var my = &wsDeflate{
+		threshold:               threshold,
+		serverNoContextTakeover: serverNoContextTakeover,
+		clientNoContextTakeover: clientNoContextTakeover,
+	}
+
+	return my
+}
+
+func (my *wsDeflate) needCompress(data []byte) bool {
+	return len(data) >= my.threshold
+}
+
+// compress 返回的数据已经去掉了结尾的0x00 0x00 0xff 0xff, 可以直接作为rsv1帧的payload
+func (my *wsDeflate) compress(data []byte) ([]byte, error) {
+	my.writeLock.Lock()
+	defer my.writeLock.Unlock()
+
+	my.writeBuffer.Reset()
+	if my.writer == nil {
+		var writer, err = flate.NewWriter(&my.writeBuffer, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		my.writer = writer
+	} else if my.serverNoContextTakeover {
+		my.writer.Reset(&my.writeBuffer)
+	}
+
+	if _, err := my.writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := my.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	var compressed = bytes.TrimSuffix(my.writeBuffer.Bytes(), deflateTail)
+	var result = make([]byte, len(compressed))
+	copy(result, compressed)
+
+	return result, nil
+}
+
+// decompress 只在watcher的goroutine中调用, 不需要加锁
+func (my *wsDeflate) decompress(data []byte) ([]byte, error) {
+	var src = io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateReadTail))
+	if my.reader == nil {
+		my.reader = flate.NewReaderDict(src, my.readDict)
+	} else if err := my.reader.(flate.Resetter).Reset(src, my.readDict); err != nil {
+		return nil, err
+	}
+
+	// 限制解压后的大小, 防止zip炸弹
+	const maxSize = codec.HeadLength + codec.MaxPacketSize
+	var result, err = ioutil.ReadAll(io.LimitReader(my.reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > maxSize {
+		return nil, codec.ErrPacketSizeExcced
+	}
+
+	if !my.clientNoContextTakeover {
+		my.readDict = append(my.readDict, result...)
+		if len(my.readDict) > deflateWindowSize {
+			my.readDict = append([]byte(nil), my.readDict[len(my.readDict)-deflateWindowSize:]...)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package epoll
+
+import (
+	"bytes"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/packet"
+	"strings"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// newDeflateDialer 与浏览器一样只发送permessage-deflate, 不带其它参数
+func newDeflateDialer() ws.Dialer {
+	return ws.Dialer{Extensions: []httphead.Option{wsflate.Parameters{}.Option()}}
+}
+
+func TestWsDeflateNegotiation(t *testing.T) {
+	var cases = []struct {
+		name       string
+		dialer     ws.Dialer
+		opts       []AcceptorOption
+		negotiated bool
+	}{
+		{name: "both enabled", dialer: newDeflateDialer(), opts: []AcceptorOption{WithDeflate(true)}, negotiated: true},
+		{name: "server disabled", dialer: newDeflateDialer()},
+		{name: "client asks server no takeover", dialer: ws.Dialer{Extensions: []httphead.Option{wsflate.DefaultParameters.Option()}}, opts: []AcceptorOption{WithDeflate(true)}},
+		{name: "both no takeover", dialer: ws.Dialer{Extensions: []httphead.Option{wsflate.DefaultParameters.Option()}}, opts: []AcceptorOption{WithDeflate(true), WithDeflateNoContextTakeover(true, true)}, negotiated: true},
+		{name: "client not offered", dialer: ws.Dialer{}, opts: []AcceptorOption{WithDeflate(true)}},
+	}
+
+	for _, item := range cases {
+		var _, wsConn = dialTestWs(t, item.dialer, item.opts...)
+		if negotiated := wsConn.deflate != nil; negotiated != item.negotiated {
+			t.Errorf("%s: negotiated=%v, expect %v", item.name, negotiated, item.negotiated)
+		}
+	}
+}
+
+// TestWsDeflateAcceptedParameters client的offer与server的默认参数不同时, 按双方实际约定的参数压缩与解压
+func TestWsDeflateAcceptedParameters(t *testing.T) {
+	var cases = []struct {
+		name                    string
+		offer                   wsflate.Parameters
+		opts                    []AcceptorOption
+		serverNoContextTakeover bool
+		clientNoContextTakeover bool
+	}{
+		{name: "defaults", opts: []AcceptorOption{WithDeflate(true)}},
+		{name: "client offers client no takeover", offer: wsflate.Parameters{ClientNoContextTakeover: true}, opts: []AcceptorOption{WithDeflate(true)}, clientNoContextTakeover: true},
+		{name: "server wants server no takeover", opts: []AcceptorOption{WithDeflate(true), WithDeflateNoContextTakeover(true, false)}, serverNoContextTakeover: true},
+		{name: "server wants client no takeover", opts: []AcceptorOption{WithDeflate(true), WithDeflateNoContextTakeover(false, true)}, clientNoContextTakeover: true},
+		{name: "client asks both", offer: wsflate.DefaultParameters, opts: []AcceptorOption{WithDeflate(true), WithDeflateNoContextTakeover(true, false)}, serverNoContextTakeover: true, clientNoContextTakeover: true},
+	}
+
+	for _, item := range cases {
+		var dialer = ws.Dialer{Extensions: []httphead.Option{item.offer.Option()}}
+		var _, wsConn = dialTestWs(t, dialer, item.opts...)
+		if wsConn.deflate == nil {
+			t.Errorf("%s: deflate should be negotiated", item.name)
+			continue
+		}
+
+		var deflate = wsConn.deflate
+		if deflate.serverNoContextTakeover != item.serverNoContextTakeover || deflate.clientNoContextTakeover != item.clientNoContextTakeover {
+			t.Errorf("%s: server=%v client=%v, expect server=%v client=%v", item.name, deflate.serverNoContextTakeover, deflate.clientNoContextTakeover,
+				item.serverNoContextTakeover, item.clientNoContextTakeover)
+		}
+	}
+}
+
+func TestWsDeflateRoundTrip(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, newDeflateDialer(), WithDeflate(true), WithDeflateThreshold(0))
+	var client = newWsDeflate(0, false, false)
+
+	var data, _ = codec.NewPomeloPacketEncoder().Encode(packet.Data, []byte(strings.Repeat("hello ", 100)))
+	var compressed, err = client.compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frame = ws.NewBinaryFrame(compressed)
+	frame.Header.Rsv = ws.Rsv(true, false, false)
+	writeClientFrame(t, conn, frame)
+	var msg = receiveServerMessage(t, wsConn)
+	if msg.Err != nil || !bytes.Equal(msg.Data, data) {
+		t.Fatalf("unexpected message: len(data)=%d err=%v", len(msg.Data), msg.Err)
+	}
+
+	if _, err := wsConn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	received, err := ws.ReadFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !received.Header.Rsv1() || len(received.Payload) >= len(data) {
+		t.Fatalf("expect a compressed frame, rsv1=%v len(payload)=%d", received.Header.Rsv1(), len(received.Payload))
+	}
+
+	decompressed, err := client.decompress(received.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed payload mismatch")
+	}
+}
+
+func TestWsDeflateContextTakeover(t *testing.T) {
+	var writer = newWsDeflate(0, false, false)
+	var reader = newWsDeflate(0, false, false)
+
+	// 开启context takeover时, 后一条消息引用前一条消息的滑动窗口, 因此第二条消息压缩得更小
+	var text = []byte(strings.Repeat("context takeover ", 50))
+	var sizes []int
+	for i := 0; i < 2; i++ {
+		var compressed, err = writer.compress(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sizes = append(sizes, len(compressed))
+		result, err := reader.decompress(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(result, text) {
+			t.Fatalf("message %d mismatch", i)
+		}
+	}
+
+	if sizes[1] >= sizes[0] {
+		t.Fatalf("expect the second message smaller, sizes=%v", sizes)
+	}
+}
+
+func TestWsDeflateSizeLimit(t *testing.T) {
+	var writer = newWsDeflate(0, true, true)
+	var compressed, err = writer.compress(make([]byte, wsMaxMessageSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newWsDeflate(0, true, true).decompress(compressed); err != codec.ErrPacketSizeExcced {
+		t.Fatalf("expect ErrPacketSizeExcced, got err=%v", err)
+	}
+}
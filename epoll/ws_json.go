@@ -0,0 +1,169 @@
+package epoll
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/util/compression"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+websocket的json文本模式, 方便QA在浏览器console中直接调试:
+1. client发送text帧: {"id":1,"route":"room.enter","body":{...}}, id为0或者缺省时按notify处理
+2. server回复text帧: {"type":"response","id":1,"body":{...}}, 出错时body换成error
+3. server推送text帧: {"type":"push","route":"room.chat","body":{...}}
+
+默认情况下进入json模式的链接不需要pomelo的handshake, 收到第一个text帧时server会替client补上handshake与handshakeAck;
+使用WithJsonHandshake(true)时, client必须先发送{"type":"handshake","body":{...}}, 否则链接会被关闭.
+pomelo的heartbeat包会被转换成websocket的ping帧, 浏览器会自动回复pong, 因此console里不会看到心跳消息
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const (
+	jsonTypeHandshake = "handshake"
+	jsonTypeResponse  = "response"
+	jsonTypePush      = "push"
+	jsonTypeKick      = "kick"
+)
+
+type jsonEnvelope struct {
+	Type  string          `json:"type,omitempty"`
+	Id    uint            `json:"id,omitempty"`
+	Route string          `json:"route,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// ErrJsonHandshakeRequired 要求显式握手时, client在handshake之前发送了其它text帧
+var ErrJsonHandshakeRequired = errors.New("json client must send handshake first")
+
+var (
+	jsonPacketEncoder  = codec.NewPomeloPacketEncoder()
+	jsonPacketDecoder  = codec.NewPomeloPacketDecoder()
+	jsonMessageEncoder = message.NewMessagesEncoder(false)
+)
+
+// encodeJsonHandshake 为json模式的client生成handshake与handshakeAck, body为空时使用{}
+func encodeJsonHandshake(body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+
+	handshake, err := jsonPacketEncoder.Encode(packet.Handshake, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ack, err := jsonPacketEncoder.Encode(packet.HandshakeAck, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(handshake, ack...), nil
+}
+
+func decodeJsonEnvelope(payload []byte) (*jsonEnvelope, error) {
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, err
+	}
+
+	return &envelope, nil
+}
+
+// encodeJsonRequest 将client发来的json信封转换为pomelo的data包
+func encodeJsonRequest(envelope *jsonEnvelope) ([]byte, error) {
+	var msg = message.Message{Type: message.Notify, Route: envelope.Route, Data: envelope.Body}
+	if envelope.Id > 0 {
+		msg.Type = message.Request
+		msg.Id = envelope.Id
+	}
+
+	if len(msg.Data) == 0 {
+		msg.Data = []byte("{}")
+	}
+
+	data, err := jsonMessageEncoder.Encode(&msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonPacketEncoder.Encode(packet.Data, data)
+}
+
+// encodeJsonFrames 将server发出的pomelo包转换为json信封, 返回的isHeartbeat表示其中包含心跳包
+func encodeJsonFrames(data []byte) (frames [][]byte, isHeartbeat bool, err error) {
+	packets, err := jsonPacketDecoder.Decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, p := range packets {
+		var envelope jsonEnvelope
+		switch p.Type {
+		case packet.Heartbeat:
+			isHeartbeat = true
+			continue
+		case packet.Handshake:
+			var body = p.Data
+			if compression.IsCompressed(body) {
+				if body, err = compression.InflateData(body); err != nil {
+					return nil, false, err
+				}
+			}
+
+			envelope = jsonEnvelope{Type: jsonTypeHandshake, Body: toRawJson(body)}
+		case packet.Kick:
+			envelope = jsonEnvelope{Type: jsonTypeKick}
+		case packet.Data:
+			msg, err := message.Decode(p.Data)
+			if err != nil {
+				return nil, false, err
+			}
+
+			envelope = jsonEnvelope{Id: msg.Id, Route: msg.Route}
+			if msg.Type == message.Response {
+				envelope.Type = jsonTypeResponse
+			} else {
+				envelope.Type = jsonTypePush
+			}
+
+			if msg.Err {
+				envelope.Error = toRawJson(msg.Data)
+			} else {
+				envelope.Body = toRawJson(msg.Data)
+			}
+		default:
+			continue
+		}
+
+		frame, err := json.Marshal(envelope)
+		if err != nil {
+			return nil, false, err
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames, isHeartbeat, nil
+}
+
+// toRawJson handler返回的[]byte不一定是json, 这种情况下按字符串输出
+func toRawJson(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if json.Valid(data) {
+		return data
+	}
+
+	var text, _ = json.Marshal(string(data))
+	return text
+}
@@ -0,0 +1,164 @@
+package epoll
+
+import (
+	"encoding/json"
+	"github.com/gobwas/ws"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/conn/packet"
+	"net"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func decodeServerPackets(t *testing.T, msg Message) []*packet.Packet {
+	if msg.Err != nil {
+		t.Fatalf("unexpected err=%v", msg.Err)
+	}
+
+	var packets, err = jsonPacketDecoder.Decode(msg.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packets
+}
+
+func readClientText(t *testing.T, conn net.Conn) jsonEnvelope {
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var frame, err = ws.ReadFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Header.OpCode != ws.OpText {
+		t.Fatalf("expect text frame, got op=%v", frame.Header.OpCode)
+	}
+
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(frame.Payload, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	return envelope
+}
+
+func encodeServerMessage(t *testing.T, msg *message.Message) []byte {
+	var data, err = jsonMessageEncoder.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frameData, err := jsonPacketEncoder.Encode(packet.Data, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return frameData
+}
+
+func TestWsJsonAutoHandshake(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{}, WithJsonTextMode(true))
+	writeClientFrame(t, conn, ws.NewTextFrame([]byte(`{"id":1,"route":"room.enter","body":{"name":"kitty"}}`)))
+
+	// 第一个text帧到达时, server替client补上handshake与handshakeAck
+	var handshake = decodeServerPackets(t, receiveServerMessage(t, wsConn))
+	if len(handshake) != 2 || handshake[0].Type != packet.Handshake || handshake[1].Type != packet.HandshakeAck {
+		t.Fatalf("expect handshake and handshakeAck, got %v", handshake)
+	}
+
+	var request = decodeServerPackets(t, receiveServerMessage(t, wsConn))
+	if len(request) != 1 || request[0].Type != packet.Data {
+		t.Fatalf("expect one data packet, got %v", request)
+	}
+
+	var msg, err = message.Decode(request[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Type != message.Request || msg.Id != 1 || msg.Route != "room.enter" || string(msg.Data) != `{"name":"kitty"}` {
+		t.Fatalf("unexpected request: %v", msg)
+	}
+
+	// 进入json模式之后, server的回复也转换为json的text帧
+	var response = encodeServerMessage(t, &message.Message{Type: message.Response, Id: 1, Data: []byte(`{"greeting":"hello"}`)})
+	if _, err := wsConn.Write(response); err != nil {
+		t.Fatal(err)
+	}
+
+	var envelope = readClientText(t, conn)
+	if envelope.Type != jsonTypeResponse || envelope.Id != 1 || string(envelope.Body) != `{"greeting":"hello"}` {
+		t.Fatalf("unexpected response: %+v", envelope)
+	}
+}
+
+func TestWsJsonExplicitHandshake(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{}, WithJsonTextMode(true), WithJsonHandshake(true))
+	writeClientFrame(t, conn, ws.NewTextFrame([]byte(`{"type":"handshake","body":{"sys":{"type":"js"}}}`)))
+
+	var handshake = decodeServerPackets(t, receiveServerMessage(t, wsConn))
+	if len(handshake) != 2 || handshake[0].Type != packet.Handshake || string(handshake[0].Data) != `{"sys":{"type":"js"}}` {
+		t.Fatalf("expect client handshake body, got %v", handshake)
+	}
+
+	writeClientFrame(t, conn, ws.NewTextFrame([]byte(`{"route":"room.chat"}`)))
+	var notify = decodeServerPackets(t, receiveServerMessage(t, wsConn))
+	var msg, err = message.Decode(notify[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Type != message.Notify || msg.Route != "room.chat" || string(msg.Data) != "{}" {
+		t.Fatalf("unexpected notify: %v", msg)
+	}
+}
+
+func TestWsJsonHandshakeRequired(t *testing.T) {
+	var conn, wsConn = dialTestWs(t, ws.Dialer{}, WithJsonTextMode(true), WithJsonHandshake(true))
+	writeClientFrame(t, conn, ws.NewTextFrame([]byte(`{"id":1,"route":"room.enter"}`)))
+
+	var msg = receiveServerMessage(t, wsConn)
+	if msg.Err != ErrJsonHandshakeRequired {
+		t.Fatalf("expect ErrJsonHandshakeRequired, got err=%v", msg.Err)
+	}
+}
+
+func TestEncodeJsonFrames(t *testing.T) {
+	var heartbeat, _ = jsonPacketEncoder.Encode(packet.Heartbeat, nil)
+	var kick, _ = jsonPacketEncoder.Encode(packet.Kick, nil)
+	var push = encodeServerMessage(t, &message.Message{Type: message.Push, Route: "room.chat", Data: []byte("not json")})
+	var failed = encodeServerMessage(t, &message.Message{Type: message.Response, Id: 2, Data: []byte(`{"code":1}`), Err: true})
+
+	var data = append(append(append(append([]byte(nil), heartbeat...), push...), failed...), kick...)
+	var frames, isHeartbeat, err = encodeJsonFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isHeartbeat {
+		t.Fatal("expect isHeartbeat")
+	}
+
+	var expected = []string{
+		`{"type":"push","route":"room.chat","body":"not json"}`,
+		`{"type":"response","id":2,"error":{"code":1}}`,
+		`{"type":"kick"}`,
+	}
+
+	if len(frames) != len(expected) {
+		t.Fatalf("expect %d frames, got %d", len(expected), len(frames))
+	}
+
+	for i, frame := range frames {
+		if string(frame) != expected[i] {
+			t.Errorf("frame[%d]=%s, expect %s", i, frame, expected[i])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package epoll
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type (
+	// UpgradeData websocket升级请求中的数据, 会一直保存在WsConn上, 可以通过Session.UpgradeData()读取
+	UpgradeData struct {
+		Header   http.Header    // 升级请求的headers
+		Query    url.Values     // 升级请求url中的query参数
+		Cookies  []*http.Cookie // 升级请求中的cookies
+		Protocol string         // 协商出来的Sec-WebSocket-Protocol, 没有协商时为空
+		Value    interface{}    // UpgradeHook返回的自定义数据, 比如验证token之后得到的userId
+	}
+
+	// UpgradeHook 在websocket升级之前调用, 返回error则拒绝升级, 返回的value会保存到UpgradeData.Value中
+	UpgradeHook func(r *http.Request) (value interface{}, err error)
+
+	// UpgradeError UpgradeHook通过返回它来指定拒绝升级时的http状态码, 其它类型的error一律按403处理
+	UpgradeError struct {
+		StatusCode int
+		Reason     string
+	}
+)
+
+func newUpgradeData(r *http.Request) *UpgradeData {
+	var data = &UpgradeData{
+		Header:  r.Header.Clone(),
+		Query:   r.URL.Query(),
+		Cookies: r.Cookies(),
+	}
+
+	return data
+}
+
+// Cookie 按名字查找cookie, 找不到时返回nil
+func (data *UpgradeData) Cookie(name string) *http.Cookie {
+	for _, cookie := range data.Cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func NewUpgradeError(statusCode int, reason string) *UpgradeError {
+	return &UpgradeError{StatusCode: statusCode, Reason: reason}
+}
+
+func (err *UpgradeError) Error() string {
+	return fmt.Sprintf("statusCode=%d reason=%q", err.StatusCode, err.Reason)
+}
+
+func writeUpgradeRejection(w http.ResponseWriter, err error) {
+	if err1, ok := err.(*UpgradeError); ok && err1.StatusCode > 0 {
+		http.Error(w, err1.Reason, err1.StatusCode)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusForbidden)
+}
+
+// checkOrigin allowedOrigins为空时不做检查; 没有Origin头的请求不是来自浏览器, 不受跨站攻击的影响, 也直接放行.
+// allowedOrigins中的条目可以是"*", 完整的origin(https://game.example.com), 也可以是通配的host(*.example.com)
+func checkOrigin(allowedOrigins []string, r *http.Request) bool {
+	if len(allowedOrigins) == 0 {
+		return true
+	}
+
+	var origin = r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range allowedOrigins {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		switch {
+		case allowed == "*":
+			return true
+		case strings.EqualFold(allowed, origin):
+			return true
+		case !strings.Contains(allowed, "://") && matchOriginHost(allowed, u):
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOriginHost 只有allowed中带了端口时才比较端口, 因此"*.example.com"可以匹配"https://game.example.com:8443"
+func matchOriginHost(allowed string, u *url.URL) bool {
+	var pattern = allowed
+	if host, port, err := net.SplitHostPort(allowed); err == nil {
+		if port != u.Port() {
+			return false
+		}
+
+		pattern = host
+	}
+
+	var host = strings.ToLower(u.Hostname())
+	if pattern == host {
+		return true
+	}
+
+	return strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])
+}
@@ -0,0 +1,45 @@
+package epoll
+
+import (
+	"net/http"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestCheckOrigin(t *testing.T) {
+	var cases = []struct {
+		allowed []string
+		origin  string
+		expect  bool
+	}{
+		{allowed: nil, origin: "https://evil.com", expect: true},
+		{allowed: []string{"game.example.com"}, origin: "", expect: true},
+		{allowed: []string{"*"}, origin: "https://evil.com", expect: true},
+		{allowed: []string{"https://game.example.com"}, origin: "https://game.example.com", expect: true},
+		{allowed: []string{"https://game.example.com"}, origin: "http://game.example.com", expect: false},
+		{allowed: []string{"game.example.com"}, origin: "https://game.example.com:8443", expect: true},
+		{allowed: []string{"*.example.com"}, origin: "https://game.example.com:8443", expect: true},
+		{allowed: []string{"*.example.com"}, origin: "https://example.com.evil.com", expect: false},
+		{allowed: []string{"game.example.com:8443"}, origin: "https://game.example.com:8443", expect: true},
+		{allowed: []string{"game.example.com:8443"}, origin: "https://game.example.com:9443", expect: false},
+		{allowed: []string{"*.example.com:8443"}, origin: "https://game.example.com", expect: false},
+		{allowed: []string{"game.example.com"}, origin: "https://other.example.com", expect: false},
+	}
+
+	for _, item := range cases {
+		var r, _ = http.NewRequest(http.MethodGet, "http://localhost/ws", nil)
+		if item.origin != "" {
+			r.Header.Set("Origin", item.origin)
+		}
+
+		if checkOrigin(item.allowed, r) != item.expect {
+			t.Errorf("allowed=%v origin=%q, expect %v", item.allowed, item.origin, item.expect)
+		}
+	}
+}
@@ -1,26 +1,84 @@
 package road
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"github.com/lixianmin/logo"
+	"sync"
+)
 
 /********************************************************************
 created:    2020-09-02
 author:     lixianmin
 
+2026-10-19 Error支持details与cause:
+1. details会序列化后发给client, 比如参数校验失败时每个字段的错误
+2. cause是内部的错误, 不会发给client, 但会出现在Error()中, 从而打印到日志里
+3. errors.Is()按code比较, 因此带了cause或details的副本仍然可以与ErrHandlerTimeout这类预定义的错误匹配
+
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
-var ErrTriggerRateLimit = NewError("ErrTriggerRateLimit", "please send request more slowly")
-var ErrKickedByRateLimit = NewError("KickedByRateLimit", "cost too many tokens in a rate limit window")
+// ErrorPolicy 决定handler返回的非*Error的错误, 以及code没有注册过的*Error如何发给client
+type ErrorPolicy int
+
+const (
+	ErrorPolicyExpose ErrorPolicy = iota // 默认值, 使用PlainError作为code, 原始的错误信息作为message
+	ErrorPolicyHide                      // 替换为ErrInternal, 原始的错误只打印到日志, 用于生产环境. 只有RegisterErrorCode()注册过的code才会发给client
+)
+
+var (
+	errorLock     sync.RWMutex
+	errorMessages = make(map[string]string) // 通过RegisterErrorCode()注册的默认错误信息
+)
+
+var ErrTriggerRateLimit = RegisterErrorCode("ErrTriggerRateLimit", "please send request more slowly")
+var ErrKickedByRateLimit = RegisterErrorCode("KickedByRateLimit", "cost too many tokens in a rate limit window")
+var ErrSendingQueueFull = RegisterErrorCode("ErrSendingQueueFull", "the sending queue of session is full")
+var ErrSlowConsumer = RegisterErrorCode("SlowConsumer", "the sending queue of session exceeds the limit")
+var ErrSessionClosed = RegisterErrorCode("SessionClosed", "session is closed")
+var ErrTaskQueueDisabled = RegisterErrorCode("TaskQueueDisabled", "session task queue is not enabled, see WithSessionTaskQueue()")
+var ErrTaskQueueFull = RegisterErrorCode("TaskQueueFull", "task queue is full")
+var ErrHandlerTimeout = RegisterErrorCode("HandlerTimeout", "handler does not finish before deadline")
+var ErrRouteDisabled = RegisterErrorCode("RouteDisabled", "route is temporarily disabled")
+var ErrRouteNotFound = RegisterErrorCode("RouteNotFound", "route is not found")
+var ErrInvalidArgument = RegisterErrorCode("InvalidArgument", "invalid argument")
+var ErrInternal = RegisterErrorCode("InternalError", "internal server error")
 
 type Error struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"` // 附加信息, 比如参数校验失败时每个字段的错误
+	cause   error       // 内部的错误, 不发给client
+}
+
+// RegisterErrorCode 注册code的默认错误信息, 之后NewError(code, "")会使用这个信息. 返回的*Error可以作为预定义的错误使用
+func RegisterErrorCode(code string, message string) *Error {
+	errorLock.Lock()
+	errorMessages[code] = message
+	errorLock.Unlock()
+
+	return &Error{Code: code, Message: message}
 }
 
+// IsRegisteredErrorCode code是否通过RegisterErrorCode()注册过
+func IsRegisteredErrorCode(code string) bool {
+	errorLock.RLock()
+	var _, ok = errorMessages[code]
+	errorLock.RUnlock()
+
+	return ok
+}
+
+// NewError format为空时, 使用RegisterErrorCode()注册的默认错误信息
 func NewError(code string, format string, args ...interface{}) *Error {
 	var message = format
 	if len(args) > 0 {
 		message = fmt.Sprintf(format, args...)
+	} else if message == "" {
+		errorLock.RLock()
+		message = errorMessages[code]
+		errorLock.RUnlock()
 	}
 
 	var err = &Error{
@@ -31,15 +89,63 @@ func NewError(code string, format string, args ...interface{}) *Error {
 	return err
 }
 
+// WrapError 用code与message包装一个内部错误, cause只打印到日志, 不发给client
+func WrapError(cause error, code string, format string, args ...interface{}) *Error {
+	var err = NewError(code, format, args...)
+	err.cause = cause
+	return err
+}
+
+// WithCause 返回一个带有cause的副本, 不修改预定义的错误
+func (err *Error) WithCause(cause error) *Error {
+	var clone = *err
+	clone.cause = cause
+	return &clone
+}
+
+// WithDetails 返回一个带有details的副本, 不修改预定义的错误
+func (err *Error) WithDetails(details interface{}) *Error {
+	var clone = *err
+	clone.Details = details
+	return &clone
+}
+
 func (err *Error) Error() string {
+	if err.cause != nil {
+		return fmt.Sprintf("code=%q message=%q cause=%q", err.Code, err.Message, err.cause.Error())
+	}
+
 	return fmt.Sprintf("code=%q message=%q", err.Code, err.Message)
 }
 
-func checkCreateError(err error) *Error {
-	if err1, ok := err.(*Error); ok {
+// Unwrap 返回cause, 用于errors.Is()与errors.As()继续匹配内部的错误
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// Is 按code比较, 因此errors.Is(err, ErrHandlerTimeout)对带了cause或details的副本同样成立
+func (err *Error) Is(target error) bool {
+	var other, ok = target.(*Error)
+	return ok && other != nil && other.Code == err.Code
+}
+
+// checkCreateError 转换为发给client的*Error. 通过fmt.Errorf("%w")包装过的*Error同样可以识别.
+// 日志中打印的是原始的err, 这样fmt.Errorf()附加的上下文不会丢失
+func checkCreateError(err error, policy ErrorPolicy) *Error {
+	var err1 *Error
+	if errors.As(err, &err1) && (policy != ErrorPolicyHide || IsRegisteredErrorCode(err1.Code)) {
+		if err1.cause != nil || error(err1) != err {
+			logo.Info("reply error to client, err=%q", err.Error())
+		}
+
 		return err1
 	}
 
+	if policy == ErrorPolicyHide {
+		logo.Info("hide internal error from client, err=%q", err.Error())
+		return ErrInternal.WithCause(err)
+	}
+
 	var err2 = &Error{
 		Code:    "PlainError",
 		Message: err.Error(),
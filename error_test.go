@@ -0,0 +1,73 @@
+package road
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestErrorIsAs(t *testing.T) {
+	var cause = errors.New("db is down")
+	var cases = []struct {
+		name   string
+		err    error
+		target *Error
+		isSame bool
+	}{
+		{name: "same", err: ErrHandlerTimeout, target: ErrHandlerTimeout, isSame: true},
+		{name: "with cause", err: ErrHandlerTimeout.WithCause(cause), target: ErrHandlerTimeout, isSame: true},
+		{name: "with details", err: ErrInvalidArgument.WithDetails([]string{"name"}), target: ErrInvalidArgument, isSame: true},
+		{name: "new error", err: NewError("HandlerTimeout", "too slow"), target: ErrHandlerTimeout, isSame: true},
+		{name: "wrapped", err: fmt.Errorf("enter room: %w", ErrRouteDisabled.WithCause(cause)), target: ErrRouteDisabled, isSame: true},
+		{name: "other code", err: ErrHandlerTimeout, target: ErrRouteDisabled},
+		{name: "plain error", err: cause, target: ErrInternal},
+	}
+
+	for _, item := range cases {
+		if errors.Is(item.err, item.target) != item.isSame {
+			t.Errorf("%s: errors.Is()=%v, expect %v", item.name, !item.isSame, item.isSame)
+		}
+
+		var err1 *Error
+		if errors.As(item.err, &err1) && item.isSame && err1.Code != item.target.Code {
+			t.Errorf("%s: errors.As() got code=%q, expect %q", item.name, err1.Code, item.target.Code)
+		}
+	}
+
+	if !errors.Is(WrapError(cause, "InternalError", ""), cause) {
+		t.Fatal("errors.Is() should match the cause")
+	}
+}
+
+func TestCheckCreateError(t *testing.T) {
+	var cause = errors.New("db is down")
+	var cases = []struct {
+		name    string
+		err     error
+		policy  ErrorPolicy
+		code    string
+		message string
+	}{
+		{name: "expose plain", err: cause, policy: ErrorPolicyExpose, code: "PlainError", message: "db is down"},
+		{name: "expose unregistered", err: NewError("DbError", "db is down"), policy: ErrorPolicyExpose, code: "DbError", message: "db is down"},
+		{name: "expose wrapped", err: fmt.Errorf("enter: %w", ErrRouteDisabled), policy: ErrorPolicyExpose, code: "RouteDisabled", message: ErrRouteDisabled.Message},
+		{name: "hide plain", err: cause, policy: ErrorPolicyHide, code: "InternalError", message: ErrInternal.Message},
+		{name: "hide unregistered", err: NewError("DbError", "db is down"), policy: ErrorPolicyHide, code: "InternalError", message: ErrInternal.Message},
+		{name: "hide registered", err: ErrHandlerTimeout.WithCause(cause), policy: ErrorPolicyHide, code: "HandlerTimeout", message: ErrHandlerTimeout.Message},
+		{name: "hide wrapped", err: fmt.Errorf("enter: %w", ErrRouteDisabled), policy: ErrorPolicyHide, code: "RouteDisabled", message: ErrRouteDisabled.Message},
+	}
+
+	for _, item := range cases {
+		var err = checkCreateError(item.err, item.policy)
+		if err.Code != item.code || err.Message != item.message {
+			t.Errorf("%s: code=%q message=%q, expect code=%q message=%q", item.name, err.Code, err.Message, item.code, item.message)
+		}
+	}
+}
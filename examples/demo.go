@@ -52,26 +52,30 @@ author:     lixianmin
 
 func main() {
 	//logo.Getlogo().SetFilterLevel(logo.LevelDebug)
-	listenTcp()
-	listenWebSocket()
 
-	select {}
-}
-
-func listenTcp() {
-	var address = ":4444"
-	var accept = epoll.NewTcpAcceptor(address, epoll.WithReceivedChanSize(1))
-	var app = road.NewApp(accept,
+	// 同一个App同时服务tcp与websocket, 共享handlers与sessions
+	var app = road.NewApp(nil,
 		road.WithSessionRateLimitBySecond(123456789),
-		road.WithHeartbeatInterval(2*time.Second),
-		road.WithSenderBufferSize(1))
+		road.WithHeartbeatInterval(2*time.Second))
 
 	var room = &Room{}
 	_ = app.Register(room, component.WithName("room"), component.WithNameFunc(strings.ToLower))
+	app.Start()
 	//testHook(app)
 
+	listenTcp(app)
+	go listenWebSocket(app)
+
+	select {}
+}
+
+func listenTcp(app *road.App) {
+	var address = ":4444"
+	var accept = epoll.NewTcpAcceptor(address, epoll.WithReceivedChanSize(1))
+	app.AddAcceptor(accept)
+
 	app.OnHandShaken(func(session road.Session) {
-		logo.Info("session.id=%d", session.Id())
+		logo.Info("session.id=%d, kind=%s", session.Id(), session.AcceptorKind())
 		go func() {
 			time.Sleep(5 * time.Second)
 			_ = session.Kick()
@@ -120,18 +124,13 @@ func listenTcp() {
 	}()
 }
 
-func listenWebSocket() {
+func listenWebSocket(app *road.App) {
 	const address = ":8888"
 	const path = "/ws"
 
 	var mux = http.NewServeMux()
 	var accept = epoll.NewWsAcceptor(mux, path)
-	var app = road.NewApp(accept,
-		road.WithSessionRateLimitBySecond(123456789))
-
-	var room = &Room{}
-	_ = app.Register(room, component.WithName("room"), component.WithNameFunc(strings.ToLower))
-	//testHook(app)
+	app.AddAcceptor(accept)
 
 	go func() {
 		var pClient = client.New()
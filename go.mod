@@ -1,11 +1,16 @@
 module github.com/lixianmin/road
 
-go 1.15
+go 1.18
 
 require (
+	github.com/gobwas/httphead v0.1.0
 	github.com/gobwas/ws v1.1.0
 	github.com/lixianmin/got v0.0.0-20220620071751-4e644d191526
 	github.com/lixianmin/logo v0.0.0-20220519032357-f73455888a56
 	github.com/xtaci/gaio v1.2.14
+)
+
+require (
+	github.com/gobwas/pool v0.2.1 // indirect
 	golang.org/x/sys v0.0.0-20211205182925-97ca703d548d // indirect
 )
@@ -0,0 +1,87 @@
+package road
+
+import (
+	"context"
+	"fmt"
+	"github.com/lixianmin/logo"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/route"
+	"github.com/lixianmin/road/validate"
+	"reflect"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Register()通过反射发现handler, 签名写错的方法会被静默跳过, 调用也要经过reflect.Method.Func.Call().
+Handle()/HandleNotify()使用泛型直接注册单个route, 签名在编译期检查, 调用时不经过反射, 可以与Register()混用
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// Handle 注册一个request的handler, route的格式与Register()生成的一样, 比如"Room.Enter"
+func Handle[Req any, Resp any](app *App, route string, handler func(ctx context.Context, req *Req) (*Resp, error)) error {
+	if app == nil || handler == nil {
+		return fmt.Errorf("handler: app and handler must not be nil, route=%q", route)
+	}
+
+	return app.addHandler(route, &component.Handler{
+		MessageType: message.Request,
+		NewArg:      func() interface{} { return new(Req) },
+		Invoke: func(ctx context.Context, arg interface{}) (interface{}, error) {
+			var resp, err = handler(ctx, arg.(*Req))
+			if err != nil || resp == nil {
+				return nil, err
+			}
+
+			return resp, nil
+		},
+	})
+}
+
+// HandleNotify 注册一个notify的handler, notify不需要回复client
+func HandleNotify[Req any](app *App, route string, handler func(ctx context.Context, req *Req)) error {
+	if app == nil || handler == nil {
+		return fmt.Errorf("handler: app and handler must not be nil, route=%q", route)
+	}
+
+	return app.addHandler(route, &component.Handler{
+		MessageType: message.Notify,
+		NewArg:      func() interface{} { return new(Req) },
+		Invoke: func(ctx context.Context, arg interface{}) (interface{}, error) {
+			handler(ctx, arg.(*Req))
+			return nil, nil
+		},
+	})
+}
+
+func (my *App) addHandler(name string, handler *component.Handler) error {
+	var rt, err = route.Decode(name)
+	if err != nil {
+		return err
+	}
+
+	if my.argValidation {
+		if err := validate.Compile(reflect.TypeOf(handler.NewArg())); err != nil {
+			return fmt.Errorf("handler %s: %s", name, err.Error())
+		}
+	}
+
+	my.tableLock.Lock()
+	defer my.tableLock.Unlock()
+
+	var key = rt.Short()
+	var table = my.loadTable()
+	if _, ok := table.handlers[key]; ok {
+		return fmt.Errorf("handler: route already defined: %s", key)
+	}
+
+	var next = table.clone()
+	next.handlers[key] = handler
+	delete(next.removed, key)
+	my.table.Store(next)
+	logo.Debug("route=%s", key)
+	return nil
+}
@@ -0,0 +1,75 @@
+package road
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/conn/message"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// TestHandleEndToEnd Handle()与HandleNotify()注册的route与Register()的一样可以被client调用.
+// chat没有通过Register()注册过component, 因此它的route没有handlerCounter与dispatcher
+func TestHandleEndToEnd(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var err = Handle(app, "chat.Say", func(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+		return &MemoryEnterResponse{Greeting: "say " + request.Name}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notified = make(chan string, 1)
+	err = HandleNotify(app, "chat.Shout", func(ctx context.Context, request *MemoryEnterRequest) {
+		notified <- request.Name
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if table := app.loadTable(); table.counters["chat"] != nil || table.dispatchers["chat"] != nil {
+		t.Fatal("chat should have no counter and no dispatcher")
+	}
+
+	var c, err1 = client.NewMemoryClient(accept)
+	if err1 != nil {
+		t.Fatal(err1)
+	}
+	defer c.Disconnect()
+
+	if err := c.SendNotify("chat.Shout", []byte(`{"name":"kitty"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-notified:
+		if name != "kitty" {
+			t.Fatalf("notify handler got name=%q", name)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("notify handler should be called")
+	}
+
+	var id, _ = c.SendRequest("chat.Say", []byte(`{"name":"kitty"}`))
+	var msg = receiveMessage(t, c)
+	if msg.Type != message.Response || msg.Id != id || msg.Err {
+		t.Fatalf("unexpected message: type=%v id=%d err=%v data=%s", msg.Type, msg.Id, msg.Err, msg.Data)
+	}
+
+	var response MemoryEnterResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Greeting != "say kitty" {
+		t.Fatalf("greeting=%q", response.Greeting)
+	}
+}
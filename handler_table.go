@@ -0,0 +1,211 @@
+package road
+
+import (
+	"fmt"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/route"
+	"strings"
+	"sync"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+每个session的goroutine都会并发读取handlers, 因此App把handlers, services与dispatchers放在一个只读的handlerTable中:
+1. 读取时通过atomic.Value拿到当前的快照, 不加锁
+2. Register(), Unregister(), Replace()与Handle()在锁内复制一份新的table, 修改之后整体替换
+3. 被移除的service先等正在执行的handler返回, 再调用Shutdown()
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type handlerTable struct {
+	handlers    map[string]*component.Handler // all handler method
+	services    map[string]*component.Service // all registered service
+	dispatchers map[string]*serviceDispatcher // 非DispatchSequential模式的service
+	counters    map[string]*handlerCounter    // 每个service正在执行的handler数
+	removed     map[string]struct{}           // 被Unregister()或Replace()移除的route
+}
+
+// handlerCounter 统计一个service正在执行(包括在worker队列中排队)的handler数,
+// Unregister()与Replace()等它们都返回之后再调用旧component的Shutdown()
+type handlerCounter struct {
+	lock      sync.Mutex
+	count     int
+	isRetired bool
+	doneChan  chan struct{}
+}
+
+func newHandlerTable() *handlerTable {
+	return &handlerTable{
+		handlers:    make(map[string]*component.Handler, 8),
+		services:    make(map[string]*component.Service),
+		dispatchers: make(map[string]*serviceDispatcher),
+		counters:    make(map[string]*handlerCounter),
+		removed:     make(map[string]struct{}),
+	}
+}
+
+func (my *handlerTable) clone() *handlerTable {
+	var next = &handlerTable{
+		handlers:    make(map[string]*component.Handler, len(my.handlers)),
+		services:    make(map[string]*component.Service, len(my.services)),
+		dispatchers: make(map[string]*serviceDispatcher, len(my.dispatchers)),
+		counters:    make(map[string]*handlerCounter, len(my.counters)),
+		removed:     make(map[string]struct{}, len(my.removed)),
+	}
+
+	for key, handler := range my.handlers {
+		next.handlers[key] = handler
+	}
+
+	for key, service := range my.services {
+		next.services[key] = service
+	}
+
+	for key, dispatcher := range my.dispatchers {
+		next.dispatchers[key] = dispatcher
+	}
+
+	for key, counter := range my.counters {
+		next.counters[key] = counter
+	}
+
+	for key := range my.removed {
+		next.removed[key] = struct{}{}
+	}
+
+	return next
+}
+
+func (my *handlerTable) getHandler(rt *route.Route) (*component.Handler, error) {
+	handler, ok := my.handlers[rt.Short()]
+	if !ok {
+		e := fmt.Errorf("handler: %s not found", rt.String())
+		return nil, e
+	}
+
+	return handler, nil
+}
+
+// isRemoved route曾经注册过, 但已经被Unregister()或Replace()移除
+func (my *handlerTable) isRemoved(rt *route.Route) bool {
+	var _, ok = my.removed[rt.Short()]
+	return ok
+}
+
+// hasService 通过Register()注册的service, 或者通过Handle()注册了以name为前缀的route
+func (my *handlerTable) hasService(name string) bool {
+	if _, ok := my.services[name]; ok {
+		return true
+	}
+
+	var prefix = name + "."
+	for key := range my.handlers {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkCollisions 返回s中与已经注册的route(比如通过Handle()注册的)冲突的handler
+func (my *handlerTable) checkCollisions(s *component.Service) []error {
+	var errs []error
+	for name := range s.Handlers {
+		var route1 = fmt.Sprintf("%s.%s", s.Name, name)
+		if _, ok := my.handlers[route1]; ok {
+			errs = append(errs, fmt.Errorf("route %s is already defined", route1))
+		}
+	}
+
+	return errs
+}
+
+func (my *handlerTable) addService(s *component.Service, dispatcher *serviceDispatcher) {
+	my.services[s.Name] = s
+	my.counters[s.Name] = &handlerCounter{}
+	if dispatcher != nil {
+		my.dispatchers[s.Name] = dispatcher
+	}
+
+	for name, handler := range s.Handlers {
+		var route1 = fmt.Sprintf("%s.%s", s.Name, name)
+		my.handlers[route1] = handler
+		delete(my.removed, route1)
+	}
+}
+
+// removeService 只删除属于这个service的handler, 通过Handle()注册的同名route不受影响
+func (my *handlerTable) removeService(s *component.Service) {
+	delete(my.services, s.Name)
+	delete(my.dispatchers, s.Name)
+	delete(my.counters, s.Name)
+
+	for name, handler := range s.Handlers {
+		var route1 = fmt.Sprintf("%s.%s", s.Name, name)
+		if my.handlers[route1] == handler {
+			delete(my.handlers, route1)
+			my.removed[route1] = struct{}{}
+		}
+	}
+}
+
+// enter 计入一个handler, service已经被移除时返回false, 调用方需要重新读取table. 通过Handle()注册的route没有counter
+func (my *handlerCounter) enter() bool {
+	if my == nil {
+		return true
+	}
+
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	if my.isRetired {
+		return false
+	}
+
+	my.count++
+	return true
+}
+
+func (my *handlerCounter) leave() {
+	if my == nil {
+		return
+	}
+
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	my.count--
+	if my.count == 0 && my.doneChan != nil {
+		close(my.doneChan)
+		my.doneChan = nil
+	}
+}
+
+// retire 之后enter()都返回false, 等待正在执行的handler全部返回, 最多等待timeout
+func (my *handlerCounter) retire(timeout time.Duration) bool {
+	my.lock.Lock()
+	my.isRetired = true
+	if my.count == 0 {
+		my.lock.Unlock()
+		return true
+	}
+
+	var doneChan = make(chan struct{})
+	my.doneChan = doneChan
+	my.lock.Unlock()
+
+	var timer = time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-doneChan:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
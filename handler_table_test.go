@@ -0,0 +1,245 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/message"
+	"github.com/lixianmin/road/route"
+	"strings"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type MemoryStrict struct{}
+
+func (my *MemoryStrict) Enter(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+	return &MemoryEnterResponse{}, nil
+}
+
+// Leave 返回值不是指针, 不能作为handler
+func (my *MemoryStrict) Leave(ctx context.Context, request *MemoryEnterRequest) (MemoryEnterResponse, error) {
+	return MemoryEnterResponse{}, nil
+}
+
+func handleStrictEnter(t *testing.T, app *App) {
+	var err = Handle(app, "strict.Enter", func(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+		return nil, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRegisterStrictCombined 被跳过的方法与route冲突汇总在同一个错误中
+func TestRegisterStrictCombined(t *testing.T) {
+	var app, _ = newTestApp(t)
+	handleStrictEnter(t, app)
+
+	var err = app.Register(&MemoryStrict{}, component.WithName("strict"), component.WithStrict())
+	var extracted, ok = err.(*component.ExtractError)
+	if !ok || len(extracted.Errors) != 2 {
+		t.Fatalf("expect an ExtractError with 2 errors, got %v", err)
+	}
+
+	var text = err.Error()
+	if !strings.Contains(text, "Leave") || !strings.Contains(text, "strict.Enter") {
+		t.Fatalf("unexpected error: %s", text)
+	}
+
+	if _, ok := app.loadTable().services["strict"]; ok {
+		t.Fatal("service should not be registered")
+	}
+}
+
+func TestRegisterOverridesHandle(t *testing.T) {
+	var app, _ = newTestApp(t)
+	handleStrictEnter(t, app)
+
+	if err := app.Register(&MemoryStrict{}, component.WithName("strict")); err != nil {
+		t.Fatal(err)
+	}
+
+	var handler = app.loadTable().handlers["strict.Enter"]
+	if handler == nil || handler.Invoke != nil {
+		t.Fatal("non-strict Register() should override the route registered by Handle()")
+	}
+}
+
+// MemorySlow Wait()一直阻塞到release被关闭
+type MemorySlow struct {
+	entered  chan struct{}
+	release  chan struct{}
+	shutdown chan struct{}
+}
+
+func newMemorySlow() *MemorySlow {
+	return &MemorySlow{
+		entered:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+		shutdown: make(chan struct{}),
+	}
+}
+
+func (my *MemorySlow) Wait(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+	my.entered <- struct{}{}
+	<-my.release
+	return &MemoryEnterResponse{}, nil
+}
+
+func (my *MemorySlow) Shutdown() {
+	close(my.shutdown)
+}
+
+func TestUnregisterRouteNotFound(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	if err := app.Unregister("room"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 连续请求两次, 确认session没有被关闭
+	for i := 0; i < 2; i++ {
+		var id, _ = c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`))
+		var msg = receiveMessage(t, c)
+		if msg.Type != message.Response || msg.Id != id || !msg.Err || !strings.Contains(string(msg.Data), "RouteNotFound") {
+			t.Fatalf("expect RouteNotFound, got type=%v err=%v data=%s", msg.Type, msg.Err, msg.Data)
+		}
+	}
+}
+
+// TestUnknownRouteClosesSession 从来没有注册过的route仍然断开网络, 只有被移除的route才回复ErrRouteNotFound
+func TestUnknownRouteClosesSession(t *testing.T) {
+	var app, accept = newTestApp(t)
+	var closedChan = make(chan struct{})
+	onHandShakenSync(app, func(session Session) {
+		session.OnClosed(func() {
+			close(closedChan)
+		})
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	_, _ = c.SendRequest("nobody.Enter", []byte(`{"name":"kitty"}`))
+	select {
+	case <-closedChan:
+	case <-time.After(3 * time.Second):
+		t.Fatal("session should be closed by an unknown route")
+	}
+}
+
+// TestRegisterAfterUnregister 重新注册之后, route不再被当作已移除
+func TestRegisterAfterUnregister(t *testing.T) {
+	var app, _ = newTestApp(t)
+	if err := app.Unregister("room"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rt, _ = route.Decode("room.Enter")
+	if !app.loadTable().isRemoved(rt) {
+		t.Fatal("room.Enter should be removed")
+	}
+
+	if err := app.Register(&MemoryRoom{}, component.WithName("room")); err != nil {
+		t.Fatal(err)
+	}
+
+	if app.loadTable().isRemoved(rt) {
+		t.Fatal("room.Enter should not be removed after Register()")
+	}
+}
+
+func TestReplaceWaitsRunningHandlers(t *testing.T) {
+	for _, opt := range []component.Option{component.WithDispatchSequential(), component.WithDispatchConcurrent(2)} {
+		var app, accept = newTestApp(t)
+		var old = newMemorySlow()
+		if err := app.Register(old, component.WithName("slow"), opt); err != nil {
+			t.Fatal(err)
+		}
+		app.Start()
+
+		var c, err = client.NewMemoryClient(accept)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = c.SendRequest("slow.Wait", []byte(`{}`))
+		<-old.entered
+
+		var replaced = make(chan error, 1)
+		go func() {
+			replaced <- app.Replace(newMemorySlow(), component.WithName("slow"), opt)
+		}()
+
+		select {
+		case <-old.shutdown:
+			t.Fatal("Shutdown() should wait for the running handler")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(old.release)
+		if err := <-replaced; err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-old.shutdown:
+		default:
+			t.Fatal("Shutdown() should be called after Replace() returns")
+		}
+
+		if msg := receiveMessage(t, c); msg.Type != message.Response || msg.Err {
+			t.Fatalf("unexpected message: type=%v err=%v", msg.Type, msg.Err)
+		}
+
+		c.Disconnect()
+	}
+}
+
+type MemoryBadTagRequest struct {
+	Level int `json:"level" validate:"min=x"`
+}
+
+type MemoryBadTag struct{}
+
+func (my *MemoryBadTag) Enter(ctx context.Context, request *MemoryBadTagRequest) (*MemoryEnterResponse, error) {
+	return &MemoryEnterResponse{}, nil
+}
+
+// TestRegisterInvalidTag 开启参数校验时, tag写错在注册时就返回错误
+func TestRegisterInvalidTag(t *testing.T) {
+	var app, _ = newTestApp(t, WithArgValidation(true))
+	if err := app.Register(&MemoryBadTag{}); err == nil || !strings.Contains(err.Error(), "MemoryBadTag.Enter") {
+		t.Fatalf("expect a tag error, got %v", err)
+	}
+
+	var err = Handle(app, "bad.Enter", func(ctx context.Context, request *MemoryBadTagRequest) (*MemoryEnterResponse, error) {
+		return nil, nil
+	})
+
+	if err == nil {
+		t.Fatal("Handle() should also fail")
+	}
+
+	var app2, _ = newTestApp(t)
+	if err := app2.Register(&MemoryBadTag{}); err != nil {
+		t.Fatalf("tags are not compiled without WithArgValidation(), got %v", err)
+	}
+}
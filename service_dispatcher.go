@@ -0,0 +1,139 @@
+package road
+
+import (
+	"context"
+	"errors"
+	"github.com/lixianmin/got/loom"
+	"github.com/lixianmin/road/component"
+	"hash/fnv"
+	"sync"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+默认情况下handler在session的goroutine中按顺序执行, 这是road从pitaya分出来的原因. 但一个慢handler(比如数据库调用)
+会卡住同一个session的心跳与后续消息, 因此service可以在Register()时选择在worker池中执行:
+1. DispatchOrderedByKey: 按key哈希到固定的worker, 相同key的消息按顺序执行
+2. DispatchConcurrent: 所有worker共享一个队列, 并发执行
+
+队列满时不会阻塞session的goroutine(否则会卡住心跳), 而是直接给client回复ErrTaskQueueFull.
+service被Unregister()或Replace()之后, 以及App关闭时, worker都会先拒绝新任务, 处理完队列中剩余的任务后再退出,
+这样每个进入队列的任务都会执行并调用counter.leave()
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const dispatchQueueSize = 256
+
+// errDispatcherClosed service刚刚被Unregister()或Replace(), 由调用方自己执行task
+var errDispatcherClosed = errors.New("service dispatcher is closed")
+
+type serviceDispatcher struct {
+	mode    component.DispatchMode
+	keyFunc component.DispatchKeyFunc
+	queues  []chan func()
+	lock    sync.RWMutex // 保证Close()之后不会再有任务进入队列
+	wc      loom.WaitClose
+}
+
+// newServiceDispatcher DispatchSequential模式返回nil, handler直接在session的goroutine中执行
+func newServiceDispatcher(service *component.Service, closeChan chan struct{}) *serviceDispatcher {
+	var mode = service.DispatchMode()
+	if mode == component.DispatchSequential {
+		return nil
+	}
+
+	var workerCount = service.WorkerCount()
+	var my = &serviceDispatcher{
+		mode:    mode,
+		keyFunc: service.DispatchKey(),
+	}
+
+	if mode == component.DispatchOrderedByKey {
+		my.queues = make([]chan func(), workerCount)
+		for i := range my.queues {
+			var queue = make(chan func(), dispatchQueueSize)
+			my.queues[i] = queue
+			loom.Go(func(later loom.Later) {
+				my.goWorker(queue, closeChan)
+			})
+		}
+	} else {
+		var queue = make(chan func(), dispatchQueueSize)
+		my.queues = []chan func(){queue}
+		for i := 0; i < workerCount; i++ {
+			loom.Go(func(later loom.Later) {
+				my.goWorker(queue, closeChan)
+			})
+		}
+	}
+
+	return my
+}
+
+// dispatch 永远不会阻塞调用方(session的goroutine). 队列满时返回ErrTaskQueueFull, dispatcher已经关闭时返回errDispatcherClosed
+func (my *serviceDispatcher) dispatch(ctx context.Context, arg interface{}, task func()) error {
+	my.lock.RLock()
+	defer my.lock.RUnlock()
+
+	if my.wc.IsClosed() {
+		return errDispatcherClosed
+	}
+
+	var queue = my.queues[0]
+	if my.mode == component.DispatchOrderedByKey {
+		var h = fnv.New32a()
+		_, _ = h.Write([]byte(my.keyFunc(ctx, arg)))
+		queue = my.queues[h.Sum32()%uint32(len(my.queues))]
+	}
+
+	select {
+	case queue <- task:
+		return nil
+	default:
+		return ErrTaskQueueFull
+	}
+}
+
+func (my *serviceDispatcher) Close() error {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	return my.wc.Close(nil)
+}
+
+func (my *serviceDispatcher) goWorker(queue chan func(), closeChan chan struct{}) {
+	var stopChan = my.wc.C()
+	for {
+		select {
+		case task := <-queue:
+			runTask(task)
+		case <-stopChan:
+			drainTasks(queue)
+			return
+		case <-closeChan:
+			// App关闭时, 先Close()保证不再有任务进入队列, 再处理完剩余的任务
+			_ = my.Close()
+			drainTasks(queue)
+			return
+		}
+	}
+}
+
+func drainTasks(queue chan func()) {
+	for {
+		select {
+		case task := <-queue:
+			runTask(task)
+		default:
+			return
+		}
+	}
+}
+
+func runTask(task func()) {
+	defer loom.DumpIfPanic()
+	task()
+}
@@ -0,0 +1,94 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/component"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestDispatchQueueFull(t *testing.T) {
+	var closeChan = make(chan struct{})
+	defer close(closeChan)
+
+	var service = component.NewService(&MemoryRoom{}, []component.Option{component.WithDispatchConcurrent(1)})
+	var dispatcher = newServiceDispatcher(service, closeChan)
+
+	// 卡住唯一的worker, 然后把队列填满
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	if err := dispatcher.dispatch(context.Background(), nil, func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	for i := 0; i < dispatchQueueSize; i++ {
+		if err := dispatcher.dispatch(context.Background(), nil, func() {}); err != nil {
+			t.Fatalf("dispatch %d: unexpected err=%v", i, err)
+		}
+	}
+
+	// 队列满时立即返回, 不阻塞session的goroutine
+	if err := dispatcher.dispatch(context.Background(), nil, func() {}); err != ErrTaskQueueFull {
+		t.Fatalf("expect ErrTaskQueueFull, got err=%v", err)
+	}
+
+	close(release)
+	_ = dispatcher.Close()
+	if err := dispatcher.dispatch(context.Background(), nil, func() {}); err != errDispatcherClosed {
+		t.Fatalf("expect errDispatcherClosed, got err=%v", err)
+	}
+}
+
+// TestDispatchDrainOnAppClose App关闭时队列中剩余的任务仍然会执行, 之后的dispatch返回errDispatcherClosed
+func TestDispatchDrainOnAppClose(t *testing.T) {
+	var closeChan = make(chan struct{})
+	var service = component.NewService(&MemoryRoom{}, []component.Option{component.WithDispatchConcurrent(1)})
+	var dispatcher = newServiceDispatcher(service, closeChan)
+
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	_ = dispatcher.dispatch(context.Background(), nil, func() {
+		close(started)
+		<-release
+	})
+
+	<-started
+	const count = 10
+	var doneChan = make(chan struct{}, count)
+	for i := 0; i < count; i++ {
+		if err := dispatcher.dispatch(context.Background(), nil, func() { doneChan <- struct{}{} }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(closeChan)
+	close(release)
+	for i := 0; i < count; i++ {
+		select {
+		case <-doneChan:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("only %d queued tasks are executed after the app is closed", i)
+		}
+	}
+
+	select {
+	case <-dispatcher.wc.C():
+	case <-time.After(3 * time.Second):
+		t.Fatal("dispatcher should be closed with the app")
+	}
+
+	if err := dispatcher.dispatch(context.Background(), nil, func() {}); err != errDispatcherClosed {
+		t.Fatalf("expect errDispatcherClosed, got err=%v", err)
+	}
+}
@@ -1,12 +1,14 @@
 package road
 
 import (
+	"context"
 	"github.com/lixianmin/got/loom"
 	"github.com/lixianmin/logo"
 	"github.com/lixianmin/road/epoll"
 	"net"
 	"runtime"
 	"sync/atomic"
+	"time"
 )
 
 /********************************************************************
@@ -18,13 +20,20 @@ Copyright (C) - All Rights Reserved
 
 type Session interface {
 	Push(route string, v interface{}) error
+	PushWithPriority(route string, v interface{}, priority Priority) error
 	Kick() error
 
+	Post(task func()) error
+	PostDelayed(delay time.Duration, task func()) (CancelFunc, error)
+
 	OnHandShaken(handler func())
 	OnClosed(handler func())
+	CloseReason() error
 
 	Id() int64
+	AcceptorKind() string
 	RemoteAddr() net.Addr
+	UpgradeData() *epoll.UpgradeData
 	Attachment() *Attachment
 }
 
@@ -32,18 +41,25 @@ type sessionWrapper struct {
 	*sessionImpl
 }
 
-func NewSession(app *App, conn epoll.PlayerConn) Session {
+func NewSession(app *App, kind string, conn epoll.PlayerConn) Session {
 	var id = atomic.AddInt64(&globalIdGenerator, 1)
 	var my = &sessionWrapper{&sessionImpl{
 		app:        app,
 		id:         id,
+		kind:       kind,
 		conn:       conn,
 		attachment: &Attachment{},
 		sender:     app.getSender(id),
+		queue:      newSendingQueue(app),
+		tasks:      newSessionTasks(app),
 	}}
 
+	my.ctx, my.cancel = context.WithCancel(app.ctx)
 	logo.Info("create session(%d)", my.id)
-	loom.Go(my.goSessionLoop)
+	// handler通过SessionFromContext()拿到的是wrapper, 与OnHandShaken()中的session是同一个对象
+	loom.Go(func(later loom.Later) {
+		my.goSessionLoop(later, my)
+	})
 
 	// 参考: https://zhuanlan.zhihu.com/p/76504936
 	runtime.SetFinalizer(my, func(w *sessionWrapper) {
@@ -0,0 +1,178 @@
+package road
+
+import (
+	"github.com/lixianmin/got/loom"
+	"github.com/lixianmin/logo"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+handler之外触发的逻辑(定时器, 匹配结果, 数据库回调等)通过Post()投递到session的goroutine中执行,
+与handler串行, 从而避免与handler竞争同一个玩家的数据
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const (
+	postedTaskPending int32 = iota
+	postedTaskCanceled
+	postedTaskStarted
+)
+
+// CancelFunc 取消PostDelayed()投递的任务, 返回false表示任务已经开始执行或者已经被取消
+type CancelFunc func() bool
+
+// delayedTimers 记录PostDelayed()还没有到期的timer, session关闭时统一停止, 防止它们在session关闭之后还占着内存
+type delayedTimers struct {
+	lock     sync.Mutex
+	timers   map[*time.Timer]struct{}
+	isClosed bool
+}
+
+func newSessionTasks(app *App) chan func() {
+	if app.taskQueueSize > 0 {
+		return make(chan func(), app.taskQueueSize)
+	}
+
+	return nil
+}
+
+// Post 把task投递到session的goroutine中执行, 不会阻塞. 需要通过WithSessionTaskQueue()开启
+func (my *sessionImpl) Post(task func()) error {
+	if task == nil {
+		return nil
+	}
+
+	if my.tasks == nil {
+		return ErrTaskQueueDisabled
+	}
+
+	if my.wc.IsClosed() {
+		return ErrSessionClosed
+	}
+
+	select {
+	case my.tasks <- task:
+		return nil
+	default:
+		return ErrTaskQueueFull
+	}
+}
+
+// PostDelayed 在delay之后把task投递到session的goroutine中执行, 返回的CancelFunc可以在task开始执行之前取消它
+func (my *sessionImpl) PostDelayed(delay time.Duration, task func()) (CancelFunc, error) {
+	if task == nil {
+		return func() bool { return false }, nil
+	}
+
+	if my.tasks == nil {
+		return nil, ErrTaskQueueDisabled
+	}
+
+	var state = postedTaskPending
+	var timer = my.delayed.add(delay, func() {
+		var err = my.Post(func() {
+			if atomic.CompareAndSwapInt32(&state, postedTaskPending, postedTaskStarted) {
+				task()
+			}
+		})
+
+		if err != nil && err != ErrSessionClosed {
+			logo.Warn("session(%d) failed to post delayed task, err=%q", my.id, err)
+		}
+	})
+
+	if timer == nil {
+		return nil, ErrSessionClosed
+	}
+
+	var cancel = func() bool {
+		if atomic.CompareAndSwapInt32(&state, postedTaskPending, postedTaskCanceled) {
+			my.delayed.remove(timer)
+			timer.Stop()
+			return true
+		}
+
+		return false
+	}
+
+	return cancel, nil
+}
+
+// runPostedTask 任务与handler在同一个goroutine中执行, 执行时间过长会卡住心跳与网络消息.
+// watchdog在任务开始前启动, 因此即使任务卡死不返回, 超出预算时也能立即打印警告日志
+func (my *sessionImpl) runPostedTask(task func()) {
+	var budget = my.app.taskBudget
+	var startTime = time.Now()
+	var watchdog = time.AfterFunc(budget, func() {
+		logo.Warn("session(%d) posted task is still running after budget=%s", my.id, budget)
+	})
+
+	func() {
+		defer loom.DumpIfPanic()
+		task()
+	}()
+
+	if !watchdog.Stop() {
+		logo.Warn("session(%d) posted task costs too much time, costTime=%s, budget=%s", my.id, time.Since(startTime), budget)
+	}
+}
+
+// add session已经关闭时返回nil
+func (my *delayedTimers) add(delay time.Duration, callback func()) *time.Timer {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	if my.isClosed {
+		return nil
+	}
+
+	if my.timers == nil {
+		my.timers = make(map[*time.Timer]struct{})
+	}
+
+	// 到期时先拿到锁再读取timer, 因此一定能看到赋值之后的timer
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		my.lock.Lock()
+		delete(my.timers, timer)
+		my.lock.Unlock()
+
+		callback()
+	})
+
+	my.timers[timer] = struct{}{}
+	return timer
+}
+
+func (my *delayedTimers) remove(timer *time.Timer) {
+	my.lock.Lock()
+	delete(my.timers, timer)
+	my.lock.Unlock()
+}
+
+// close 停止所有还没有到期的timer, 之后add()都返回nil
+func (my *delayedTimers) close() {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	my.isClosed = true
+	for timer := range my.timers {
+		timer.Stop()
+	}
+
+	my.timers = nil
+}
+
+// count 还没有到期的timer数
+func (my *delayedTimers) count() int {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	return len(my.timers)
+}
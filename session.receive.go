@@ -16,7 +16,9 @@ import (
 	"github.com/lixianmin/road/route"
 	"github.com/lixianmin/road/serialize"
 	"github.com/lixianmin/road/util"
+	"github.com/lixianmin/road/validate"
 	"reflect"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,8 +29,11 @@ author:     lixianmin
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
-func (my *sessionImpl) goSessionLoop(later loom.Later) {
-	defer my.Close()
+func (my *sessionImpl) goSessionLoop(later loom.Later, session *sessionWrapper) {
+	var reason error
+	defer func() {
+		_ = my.closeWithReason(reason)
+	}()
 
 	var receivedChan = my.conn.GetReceivedChan()
 	var closeChan = my.wc.C()
@@ -39,6 +44,7 @@ func (my *sessionImpl) goSessionLoop(later loom.Later) {
 	var stepRateLimitTokens = mathx.MaxI32(1, int32(float64(heartbeatInterval)/float64(time.Second)*float64(app.rateLimitBySecond)))
 
 	var fetus = &sessionFetus{
+		session:          session,
 		lastAt:           time.Now(),
 		heartbeatTimeout: heartbeatInterval * 3,
 		rateLimitTokens:  stepRateLimitTokens,
@@ -55,17 +61,27 @@ func (my *sessionImpl) goSessionLoop(later loom.Later) {
 
 			if err := my.onHeartbeat(fetus); err != nil {
 				logo.Info("close session(%d) by onHeartbeat(), err=%q", my.id, err)
+				reason = err
 				return
 			}
 		case msg := <-receivedChan:
 			fetus.lastAt = time.Now()
+			// 协议层的keepalive(比如websocket的ping/pong)只刷新心跳时间, 不消耗限流令牌
+			if msg.IsKeepalive {
+				break
+			}
+
 			fetus.rateLimitTokens--
-			if err := my.onReceivedMessage(fetus, msg); err != nil {
+			// packet与message都直接引用msg.Data的内存, handler返回之后才能归还
+			var err = my.onReceivedMessage(fetus, msg)
+			msg.Release()
+			if err != nil {
 				logo.Info("close session(%d) by onReceivedMessage(), err=%q", my.id, err)
+				reason = err
 				return
 			}
-		//case task := <-my.tasks.C:
-		//	_ = task.Do(my)
+		case task := <-my.tasks:
+			my.runPostedTask(task)
 		case <-closeChan:
 			logo.Info("close session(%d) by calling session.Close()", my.id)
 			return
@@ -85,6 +101,15 @@ func (my *sessionImpl) onHeartbeat(fetus *sessionFetus) error {
 		return fmt.Errorf("session heartbeat timeout, lastAt=%q, heartbeatTimeout=%s", fetus.lastAt.Format(timex.Layout), fetus.heartbeatTimeout)
 	}
 
+	// 开启了协议层保活的链接(比如websocket的ping/pong)，用ping帧代替pomelo的心跳包
+	if pinger, ok := my.conn.(epoll.Pinger); ok && pinger.PingKeepalive() {
+		if err := pinger.WritePing(); err != nil {
+			return fmt.Errorf("failed to write ping in conn: %s", err.Error())
+		}
+
+		return nil
+	}
+
 	// 发送心跳包，如果网络是通的，收到心跳返回时会刷新 lastAt
 	if err := my.writeBytes(my.app.heartbeatPacketData); err != nil {
 		return fmt.Errorf("failed to write in conn: %s", err.Error())
@@ -142,7 +167,7 @@ func (my *sessionImpl) onReceivedHandshake(fetus *sessionFetus, p *packet.Packet
 }
 
 func (my *sessionImpl) onReceivedData(fetus *sessionFetus, p *packet.Packet) error {
-	item, err := my.decodeReceivedData(p)
+	item, err := my.decodeReceivedData(fetus, p)
 	if err != nil {
 		var err1 = fmt.Errorf("failed to process packet: %s", err.Error())
 		return err1
@@ -160,8 +185,7 @@ func (my *sessionImpl) onReceivedData(fetus *sessionFetus, p *packet.Packet) err
 				return err1
 			}
 
-			var err2 = my.writeBytes(data)
-			if err2 != nil {
+			if err2 := my.writeReply(data); err2 != nil {
 				return err2
 			}
 		}
@@ -174,27 +198,133 @@ func (my *sessionImpl) onReceivedData(fetus *sessionFetus, p *packet.Packet) err
 		return nil
 	}
 
+	// 被关闭的route直接回复错误码, 但不断开网络
+	if err := my.app.checkGate(my.id, item.route); err != nil {
+		return my.replyResult(item, nil, err)
+	}
+
 	// 取handler，准备处理协议
-	handler, err := my.app.getHandler(item.route)
+	// handler与dispatcher从同一份快照中读取, 避免与Replace()交错; handler返回之前, 旧的service不会被Shutdown()
+	var table, counter = my.app.acquireTable(item.route.Service)
+	var isDispatched = false
+	defer func() {
+		if !isDispatched {
+			counter.leave()
+		}
+	}()
+
+	handler, err := table.getHandler(item.route)
 	if err != nil {
+		// service刚刚被Unregister()或Replace()移除的route, 与被关闭的route一样只回复错误码, 不断开网络;
+		// 从来没有注册过的route仍然断开网络
+		if table.isRemoved(item.route) {
+			logo.Debug("session(%d) %s", my.id, err.Error())
+			return my.replyResult(item, nil, ErrRouteNotFound)
+		}
+
 		return err
 	}
 
-	payload, err := processReceivedData(item, handler, my.app.serializer, my.app.hookCallback)
-	if needReply {
-		var msg = message.Message{Type: message.Response, Id: item.msg.Id, Data: payload}
-		var data, err1 = my.encodeMessageMayError(msg, err)
-		if err1 != nil {
-			return err1
+	// 参数在session的goroutine中反序列化, 因为msg.Data引用的是池化的帧内存, 在这个方法返回之后就会被归还
+	arg, err := unmarshalHandlerArg(handler, my.app.serializer, item.msg.Data)
+	if err == nil && my.app.argValidation {
+		err = validateHandlerArg(arg)
+	}
+
+	if err != nil {
+		return my.replyResult(item, nil, err)
+	}
+
+	var dispatcher = table.dispatchers[item.route.Service]
+	if dispatcher == nil {
+		return my.processRequest(item, handler, arg)
+	}
+
+	item.msg.Data = nil
+	var dispatchErr = dispatcher.dispatch(item.ctx, arg, func() {
+		defer counter.leave()
+		if err := my.processRequest(item, handler, arg); err != nil {
+			logo.Info("session(%d) failed to process route=%q, err=%q", my.id, item.msg.Route, err)
 		}
+	})
 
-		return my.writeBytes(data)
+	switch dispatchErr {
+	case nil:
+		isDispatched = true
+		return nil
+	case errDispatcherClosed:
+		// service刚刚被Unregister()或Replace(), 它的worker已经退出, 直接在session的goroutine中执行
+		return my.processRequest(item, handler, arg)
+	default:
+		// worker处理不过来, 直接回复client而不是阻塞session的goroutine
+		return my.replyResult(item, nil, dispatchErr)
 	}
+}
 
-	return nil
+// processRequest 调用handler并回复client, 回复按message id对应到request, 因此在worker池中执行时也不会错乱
+func (my *sessionImpl) processRequest(item receivedItem, handler *component.Handler, arg interface{}) error {
+	var timeout = my.app.getHandlerTimeout(item.route)
+	if timeout <= 0 {
+		payload, err := callHandler(item, handler, arg, my.app.serializer, my.app.hookCallback)
+		return my.replyResult(item, payload, err)
+	}
+
+	var ctx, cancel = context.WithTimeout(item.ctx, timeout)
+	defer cancel()
+	item.ctx = ctx
+
+	// 超时后立即回复client, 不等handler返回; 谁先把replied从0改为1, 谁负责回复
+	var replied int32
+	var timer = time.AfterFunc(timeout, func() {
+		if atomic.CompareAndSwapInt32(&replied, 0, 1) {
+			logo.Info("session(%d) handler timeout, route=%q, timeout=%s", my.id, item.msg.Route, timeout)
+			_ = my.replyResult(item, nil, ErrHandlerTimeout)
+		}
+	})
+
+	payload, err := callHandler(item, handler, arg, my.app.serializer, my.app.hookCallback)
+	timer.Stop()
+
+	if !atomic.CompareAndSwapInt32(&replied, 0, 1) {
+		return nil
+	}
+
+	// handler响应ctx的取消, 抢在timer之前返回时, 同样按超时回复
+	if ctx.Err() == context.DeadlineExceeded {
+		logo.Info("session(%d) handler timeout, route=%q, timeout=%s", my.id, item.msg.Route, timeout)
+		return my.replyResult(item, nil, ErrHandlerTimeout)
+	}
+
+	return my.replyResult(item, payload, err)
+}
+
+func (my *sessionImpl) replyResult(item receivedItem, payload []byte, err error) error {
+	if item.msg.Type == message.Notify {
+		return nil
+	}
+
+	var msg = message.Message{Type: message.Response, Id: item.msg.Id, Data: payload}
+	var data, err1 = my.encodeMessageMayError(msg, err)
+	if err1 != nil {
+		return err1
+	}
+
+	return my.writeReply(data)
 }
 
-func (my *sessionImpl) decodeReceivedData(p *packet.Packet) (receivedItem, error) {
+// writeReply 按OverflowDropNewest或OverflowDropOldest丢弃的回复只记录日志, 不断开链接;
+// 只有慢client被断开(ErrSlowConsumer)或者session已经关闭时才返回错误, 结束session的循环
+func (my *sessionImpl) writeReply(data []byte) error {
+	var err = my.writePooledBytes(data, PriorityResponse)
+	if err == ErrSendingQueueFull {
+		logo.Info("session(%d) dropped a response as the sending queue is full", my.id)
+		return nil
+	}
+
+	return err
+}
+
+func (my *sessionImpl) decodeReceivedData(fetus *sessionFetus, p *packet.Packet) (receivedItem, error) {
 	msg, err := message.Decode(p.Data)
 	if err != nil {
 		return receivedItem{}, err
@@ -205,7 +335,17 @@ func (my *sessionImpl) decodeReceivedData(p *packet.Packet) (receivedItem, error
 		return receivedItem{}, err
 	}
 
-	var ctx = context.WithValue(context.Background(), ifs.CtxKeySession, my)
+	fetus.requestSequence++
+	var ctx = context.WithValue(my.ctx, ifs.CtxKeySession, fetus.session)
+	ctx = withRequestInfo(ctx, &requestInfo{
+		nodeId:     my.app.nodeId,
+		sessionId:  my.id,
+		sequence:   fetus.requestSequence,
+		messageId:  msg.Id,
+		route:      msg.Route,
+		msgType:    msg.Type,
+		receivedAt: fetus.lastAt,
+	})
 
 	var item = receivedItem{
 		ctx:   ctx,
@@ -216,12 +356,20 @@ func (my *sessionImpl) decodeReceivedData(p *packet.Packet) (receivedItem, error
 	return item, nil
 }
 
-func processReceivedData(data receivedItem, handler *component.Handler, serializer serialize.Serializer, hookCallback HookFunc) ([]byte, error) {
-	// First unmarshal the handler arg that will be passed to
-	// both handler and pipeline functions
-	arg, err := unmarshalHandlerArg(handler, serializer, data.msg.Data)
-	if err != nil {
-		return nil, err
+func callHandler(data receivedItem, handler *component.Handler, arg interface{}, serializer serialize.Serializer, hookCallback HookFunc) ([]byte, error) {
+	// 泛型注册的handler直接调用
+	if handler.Invoke != nil {
+		resp, err := hookCallback(func() (i interface{}, e error) {
+			return util.PInvoke(data.msg.Route, func() (interface{}, error) {
+				return handler.Invoke(data.ctx, arg)
+			}, handler.MessageType == message.Notify)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return util.SerializeOrRaw(serializer, resp)
 	}
 
 	var args []reflect.Value
@@ -247,14 +395,36 @@ func processReceivedData(data receivedItem, handler *component.Handler, serializ
 	return ret, nil
 }
 
+// validateHandlerArg 校验失败时把每个字段的错误放到ErrInvalidArgument的details中; tag写错属于代码问题, 打印日志
+func validateHandlerArg(arg interface{}) error {
+	var err = validate.Struct(arg)
+	if err == nil {
+		return nil
+	}
+
+	if fieldErrors, ok := err.(validate.Errors); ok {
+		return ErrInvalidArgument.WithDetails(fieldErrors)
+	}
+
+	logo.Warn("failed to validate handler arg, err=%q", err)
+	return err
+}
+
+// unmarshalHandlerArg payload引用的是池化的帧内存, 在handler返回之后就会被复用, 因此IsRawArg的handler拿到的是一份copy,
+// 可以放心地保存下来或者交给其它goroutine
 func unmarshalHandlerArg(handler *component.Handler, serializer serialize.Serializer, payload []byte) (interface{}, error) {
 	if handler.IsRawArg {
-		return payload, nil
+		return append([]byte(nil), payload...), nil
 	}
 
 	var arg interface{}
-	if handler.Type != nil {
+	if handler.NewArg != nil {
+		arg = handler.NewArg()
+	} else if handler.Type != nil {
 		arg = reflect.New(handler.Type.Elem()).Interface()
+	}
+
+	if arg != nil {
 		err := serializer.Unmarshal(payload, arg)
 		if err != nil {
 			return nil, err
@@ -2,9 +2,11 @@ package road
 
 import (
 	"github.com/lixianmin/logo"
+	"github.com/lixianmin/road/conn/codec"
 	"github.com/lixianmin/road/conn/message"
 	"github.com/lixianmin/road/conn/packet"
 	"github.com/lixianmin/road/util"
+	"github.com/lixianmin/road/util/bufferpool"
 )
 
 /********************************************************************
@@ -14,11 +16,19 @@ author:     lixianmin
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
+// Push 以PriorityPushHigh的优先级推送消息
 func (my *sessionImpl) Push(route string, v interface{}) error {
+	return my.PushWithPriority(route, v, PriorityPushHigh)
+}
+
+// PushWithPriority 高优先级的数据会先发送, 比如让战斗中的状态同步插到背包之类的大推送前面.
+// 同一优先级内保持推送的顺序. session已经关闭时返回ErrSessionClosed, 发送队列满时的返回值参考WithSessionQueuePolicy()
+func (my *sessionImpl) PushWithPriority(route string, v interface{}, priority Priority) error {
 	if my.wc.IsClosed() {
-		return nil
+		return ErrSessionClosed
 	}
 
+	priority = checkPushPriority(priority)
 	var payload, err = util.SerializeOrRaw(my.app.serializer, v)
 	var msg = message.Message{Type: message.Push, Route: route, Data: payload}
 	var data, err1 = my.encodeMessageMayError(msg, err)
@@ -30,10 +40,19 @@ func (my *sessionImpl) Push(route string, v interface{}) error {
 	//case my.sendingChan <- data:
 	//case <-my.wc.C():
 	//}
-	err = my.writeBytes(data)
+	err = my.writePooledBytes(data, priority)
 	return err
 }
 
+// checkPushPriority PriorityControl留给握手, 心跳与close帧, 推送不能使用它, 否则可以绕过发送队列的上限
+func checkPushPriority(priority Priority) Priority {
+	if priority < PriorityResponse || priority >= priorityCount {
+		return PriorityPushHigh
+	}
+
+	return priority
+}
+
 // Kick 强踢下线
 func (my *sessionImpl) Kick() error {
 	if my.wc.IsClosed() {
@@ -45,7 +64,12 @@ func (my *sessionImpl) Kick() error {
 		return err
 	}
 
-	return my.writeBytes(p)
+	if err := my.writeBytes(p); err != nil {
+		return err
+	}
+
+	// 支持close握手的链接(比如websocket)，在kick包之后补发一个close帧; 其它链接在kick包写出去之后直接断开
+	return my.enqueue(sendingItem{session: my, priority: PriorityControl, isClose: true})
 	//select {
 	//case my.sendingChan <- p:
 	//	logo.Info("session(%d) will be closed by Kick()", my.id)
@@ -60,7 +84,7 @@ func (my *sessionImpl) encodeMessageMayError(msg message.Message, err error) ([]
 		//logo.Info("process failed, route=%s, err=%q", msg.Route, err.Error())
 
 		// err需要支持json序列化的话，就不能是一个简单的字符串
-		var errWrap = checkCreateError(err)
+		var errWrap = checkCreateError(err, my.app.errorPolicy)
 
 		var err1 error
 		msg.Data, err1 = util.SerializeOrRaw(my.app.serializer, errWrap)
@@ -79,15 +103,39 @@ func (my *sessionImpl) encodeMessageMayError(msg message.Message, err error) ([]
 	return data, nil
 }
 
+// writeBytes 用于握手, 心跳, kick等控制数据, 它们以最高优先级发送, 并且不受发送队列上限的约束
 func (my *sessionImpl) writeBytes(data []byte) error {
 	if len(data) > 0 {
-		var item = sendingItem{session: my, data: data}
-		my.sender.sendingChan <- item
+		return my.enqueue(sendingItem{session: my, data: data, priority: PriorityControl})
+	}
+
+	return nil
+}
+
+// writePooledBytes data来自packetEncodeMessage(), sessionSender写完之后会将它归还给bufferpool
+func (my *sessionImpl) writePooledBytes(data []byte, priority Priority) error {
+	if len(data) > 0 {
+		return my.enqueue(sendingItem{session: my, data: data, priority: priority, isPooled: true})
 	}
 
 	return nil
 }
 
+// enqueue 进入session自己的发送队列, 永远不会阻塞. 队列超出上限时按OverflowPolicy处理
+func (my *sessionImpl) enqueue(item sendingItem) error {
+	var needSchedule, err = my.queue.push(item)
+	if needSchedule {
+		my.sender.schedule(my)
+	}
+
+	if err == ErrSlowConsumer {
+		logo.Info("close session(%d) as a slow consumer", my.id)
+		_ = my.closeWithReason(err)
+	}
+
+	return err
+}
+
 //func (my *sessionImpl) writeBytes(data []byte) error {
 //	if len(data) > 0 {
 //		var _, err = my.conn.Write(data)
@@ -97,17 +145,22 @@ func (my *sessionImpl) writeBytes(data []byte) error {
 //	return nil
 //}
 
+// packetEncodeMessage 在bufferpool的buffer中预留出packet的header, message直接编码在header之后,
+// 省掉message与packet两次编码各自的分配与copy. 返回的buffer需要通过writePooledBytes()发送
 func (my *sessionImpl) packetEncodeMessage(msg *message.Message) ([]byte, error) {
-	data, err := my.app.messageEncoder.Encode(msg)
+	var buffer = bufferpool.Get(codec.HeadLength + message.EncodedSize(msg))
+	data, err := my.app.messageEncoder.EncodeTo(buffer[:codec.HeadLength], msg)
 	if err != nil {
+		bufferpool.Put(buffer)
 		return nil, err
 	}
 
-	// packet encode
-	p, err := my.app.packetEncoder.Encode(packet.Data, data)
-	if err != nil {
-		return nil, err
+	var size = len(data) - codec.HeadLength
+	if size > codec.MaxPacketSize {
+		bufferpool.Put(buffer)
+		return nil, codec.ErrPacketSizeExcced
 	}
 
-	return p, nil
+	codec.WriteHeader(data, packet.Data, size)
+	return data, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/lixianmin/road/epoll"
 	"github.com/lixianmin/road/route"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,25 +22,41 @@ var (
 	globalIdGenerator int64 = 0
 )
 
+// kickFlushTimeout 没有close握手的链接, 在Kick()之后最多等待这么久让kick包写出去, 然后断开
+const kickFlushTimeout = time.Second
+
 type (
 	sessionImpl struct {
 		app        *App
 		id         int64
+		kind       string
 		conn       epoll.PlayerConn
 		attachment *Attachment
 		sender     *sessionSender
+		queue      *sendingQueue
+		tasks      chan func()     // 通过WithSessionTaskQueue()开启, 否则为nil
+		delayed    delayedTimers   // PostDelayed()还没有到期的timer
+		ctx        context.Context // 所有handler的ctx都派生自它, session关闭或者App关闭时取消
+		cancel     context.CancelFunc
 		wc         loom.WaitClose
+		reason     atomic.Value // closeReason
 
 		onHandShaken delegate
 		onClosed     delegate
 	}
 
 	sessionFetus struct {
-		isHandshakeReceived bool          // 是否接收到handshake消息
-		lastAt              time.Time     // 最后一时收到数据的时间戳
-		heartbeatTimeout    time.Duration // 用于判断心跳是否超时
-		rateLimitTokens     int32         // 限流令牌
-		rateLimitWindow     int32         // 限流窗口
+		session             *sessionWrapper // 放入handler的ctx, 与Session接口的使用方拿到的是同一个对象
+		isHandshakeReceived bool            // 是否接收到handshake消息
+		lastAt              time.Time       // 最后一时收到数据的时间戳
+		heartbeatTimeout    time.Duration   // 用于判断心跳是否超时
+		rateLimitTokens     int32           // 限流令牌
+		rateLimitWindow     int32           // 限流窗口
+		requestSequence     uint64          // 收到的data消息数, 用于生成RequestId
+	}
+
+	closeReason struct {
+		err error
 	}
 
 	receivedItem struct {
@@ -51,7 +68,16 @@ type (
 
 // Close 可以被多次调用，但只触发一次OnClosed事件
 func (my *sessionImpl) Close() error {
+	return my.closeWithReason(nil)
+}
+
+// closeWithReason 只有第一次关闭时的reason会被记录下来
+func (my *sessionImpl) closeWithReason(reason error) error {
 	return my.wc.Close(func() error {
+		my.reason.Store(closeReason{err: reason})
+		my.queue.close()
+		my.delayed.close()
+		my.cancel()
 		var err = my.conn.Close()
 		my.attachment.dispose()
 		my.onClosed.Invoke()
@@ -59,6 +85,16 @@ func (my *sessionImpl) Close() error {
 	})
 }
 
+// closeAfterFlush 等链接中已经交出去的数据都写完之后再关闭session, 最多等待timeout
+func (my *sessionImpl) closeAfterFlush(timeout time.Duration) {
+	var deadline = time.Now().Add(timeout)
+	for epoll.GetPendingWriteBytes(my.conn) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = my.Close()
+}
+
 // OnHandShaken 握手事件：收到握手消息后触发
 func (my *sessionImpl) OnHandShaken(handler func()) {
 	my.onHandShaken.Add(handler)
@@ -80,24 +116,42 @@ func (my *sessionImpl) OnClosed(handler func()) {
 // 负面：
 // 1. 这个方法只对业务有可能有用，但对网络库本身并没有意义；
 // 2. 必须谨慎使用，过长的处理时间会影响后续网络消息处理，可能导致链接超时（当然你可以选择不用）
-//func (my *sessionImpl) SendCallback(handler taskx.Handler) loom.ITask {
-//	return my.tasks.SendCallback(handler)
-//}
 //
-//// 延迟任务
-//func (my *sessionImpl) SendDelayed(delayed time.Duration, handler taskx.Handler) {
-//	my.tasks.SendDelayed(delayed, handler)
-//}
+// 现在以opt-in的方式提供Post()/PostDelayed(), 需要通过WithSessionTaskQueue()开启, 并且有超时的watchdog日志, 参考session.post.go
+
+// CloseReason 导致session关闭的原因，比如心跳超时、限流、client发来的websocket close帧(*epoll.WsCloseError)等。
+// session尚未关闭，或者是主动调用Close()关闭时返回nil
+func (my *sessionImpl) CloseReason() error {
+	if reason, ok := my.reason.Load().(closeReason); ok {
+		return reason.err
+	}
+
+	return nil
+}
 
 // Id 全局唯一id
 func (my *sessionImpl) Id() int64 {
 	return my.id
 }
 
+// AcceptorKind 链接来自哪一种acceptor, 比如epoll.KindTcp, epoll.KindWs
+func (my *sessionImpl) AcceptorKind() string {
+	return my.kind
+}
+
 func (my *sessionImpl) RemoteAddr() net.Addr {
 	return my.conn.RemoteAddr()
 }
 
+// UpgradeData websocket升级请求中的数据, 非websocket链接返回nil
+func (my *sessionImpl) UpgradeData() *epoll.UpgradeData {
+	if holder, ok := my.conn.(interface{ UpgradeData() *epoll.UpgradeData }); ok {
+		return holder.UpgradeData()
+	}
+
+	return nil
+}
+
 func (my *sessionImpl) Attachment() *Attachment {
 	return my.attachment
 }
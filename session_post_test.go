@@ -0,0 +1,77 @@
+package road
+
+import (
+	"github.com/lixianmin/road/client"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+func TestPostDelayedStoppedOnClose(t *testing.T) {
+	var app, accept = newTestApp(t, WithSessionTaskQueue(8))
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	var session = (<-sessionChan).(*sessionWrapper).sessionImpl
+	var cancels []CancelFunc
+	for i := 0; i < 3; i++ {
+		var cancel, err = session.PostDelayed(time.Hour, func() {})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cancels = append(cancels, cancel)
+	}
+
+	if !cancels[0]() || session.delayed.count() != 2 {
+		t.Fatalf("expect 2 timers after cancel, got %d", session.delayed.count())
+	}
+
+	_ = session.Close()
+	if count := session.delayed.count(); count != 0 {
+		t.Fatalf("expect all timers stopped after close, got %d", count)
+	}
+
+	if _, err := session.PostDelayed(time.Hour, func() {}); err != ErrSessionClosed {
+		t.Fatalf("expect ErrSessionClosed, got err=%v", err)
+	}
+}
+
+func TestPostDelayedRuns(t *testing.T) {
+	var app, accept = newTestApp(t, WithSessionTaskQueue(8))
+	var done = make(chan struct{})
+	onHandShakenSync(app, func(session Session) {
+		var impl = session.(*sessionWrapper).sessionImpl
+		_, _ = impl.PostDelayed(time.Millisecond, func() {
+			if impl.delayed.count() == 0 {
+				close(done)
+			}
+		})
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("delayed task did not run or its timer was not removed")
+	}
+}
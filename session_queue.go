@@ -0,0 +1,202 @@
+package road
+
+import (
+	"github.com/lixianmin/road/util/bufferpool"
+	"sync"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+每个session独立的发送队列.
+
+以前所有session共享所属sender的sendingChan, 一个慢client把sendingChan塞满之后, 同一个sender上
+其它session的push(以及调用了push的handler)都会被卡住. 现在每个session的数据先进入自己的队列,
+超出上限时按OverflowPolicy处理, 入队永远不会阻塞
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// OverflowPolicy session的发送队列超出上限时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowDisconnect OverflowPolicy = iota // 判定为慢消费者, 断开链接
+	OverflowDropOldest                       // 丢弃优先级最低的数据中最早入队的, 适合只关心最新状态的推送
+	OverflowDropNewest                       // 丢弃新入队的数据, Push()返回ErrSendingQueueFull
+)
+
+// Priority 发送的优先级, 数值越小越优先. 同一优先级内保持入队顺序, 不同优先级之间不保证顺序
+type Priority int
+
+const (
+	PriorityControl  Priority = iota // 握手, 心跳, kick, close帧等控制数据
+	PriorityResponse                 // request的回复
+	PriorityPushHigh                 // Push()的默认优先级, 比如战斗中的状态同步
+	PriorityPushLow                  // 可以延后的大推送, 比如背包, 排行榜
+	priorityCount
+)
+
+type sendingQueue struct {
+	lock        sync.Mutex
+	levels      [priorityCount][]sendingItem // 每个优先级一个FIFO队列
+	count       int                          // 队列中数据的总条数
+	size        int                          // 队列中数据的总字节数
+	isScheduled bool                         // 是否已经在sender的待发送列表中, 保证每个session同时只被调度一次
+	isWriting   bool                         // sender已经pop()出数据, 但还没有写完
+	isClosed    bool
+
+	maxMessages int // 为0时不限制
+	maxBytes    int // 为0时不限制
+	policy      OverflowPolicy
+}
+
+func newSendingQueue(app *App) *sendingQueue {
+	var my = &sendingQueue{
+		maxMessages: app.queueMaxMessages,
+		maxBytes:    app.queueMaxBytes,
+		policy:      app.queuePolicy,
+	}
+
+	return my
+}
+
+// push 返回needSchedule=true时, 调用方需要把session加入sender的待发送列表
+func (my *sendingQueue) push(item sendingItem) (needSchedule bool, err error) {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	if my.isClosed {
+		releaseSendingItem(item)
+		return false, ErrSessionClosed
+	}
+
+	// 控制数据不受上限约束, 否则慢client连心跳与kick都收不到了
+	for item.priority != PriorityControl && my.isOverflow(len(item.data)) {
+		switch my.policy {
+		case OverflowDropNewest:
+			releaseSendingItem(item)
+			return false, ErrSendingQueueFull
+		case OverflowDropOldest:
+			if !my.dropOldest(item.priority) {
+				releaseSendingItem(item)
+				return false, ErrSendingQueueFull
+			}
+		default:
+			releaseSendingItem(item)
+			return false, ErrSlowConsumer
+		}
+	}
+
+	my.levels[item.priority] = append(my.levels[item.priority], item)
+	my.count++
+	my.size += len(item.data)
+
+	needSchedule = !my.isScheduled
+	my.isScheduled = true
+	return needSchedule, nil
+}
+
+func (my *sendingQueue) isOverflow(size int) bool {
+	if my.count == 0 {
+		return false
+	}
+
+	return my.maxMessages > 0 && my.count+1 > my.maxMessages ||
+		my.maxBytes > 0 && my.size+size > my.maxBytes
+}
+
+// dropOldest 从优先级最低的队列开始丢弃最早入队的数据, 但不会为了低优先级的新数据丢弃更高优先级的数据, 也不会丢弃控制数据
+func (my *sendingQueue) dropOldest(priority Priority) bool {
+	for level := priorityCount - 1; level >= priority && level > PriorityControl; level-- {
+		var items = my.levels[level]
+		if len(items) > 0 {
+			var item = items[0]
+			releaseSendingItem(item)
+			my.count--
+			my.size -= len(item.data)
+
+			copy(items, items[1:])
+			items[len(items)-1] = sendingItem{}
+			my.levels[level] = items[:len(items)-1]
+			return true
+		}
+	}
+
+	return false
+}
+
+// pop 按优先级从高到低取出最多maxBytes字节的数据(至少一项)追加到buffer中, 返回hasMore=true时队列中还有数据, 需要重新调度
+func (my *sendingQueue) pop(maxBytes int, buffer []sendingItem) (items []sendingItem, hasMore bool) {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	var total, size = 0, 0
+	for level := range my.levels {
+		var items = my.levels[level]
+		var count = 0
+		for count < len(items) && (total == 0 || size+len(items[count].data) <= maxBytes) {
+			size += len(items[count].data)
+			buffer = append(buffer, items[count])
+			count++
+			total++
+		}
+
+		var rest = copy(items, items[count:])
+		for i := rest; i < len(items); i++ {
+			items[i] = sendingItem{}
+		}
+
+		my.levels[level] = items[:rest]
+		my.count -= count
+		if rest > 0 {
+			break
+		}
+	}
+
+	my.size -= size
+	my.isScheduled = my.count > 0
+	my.isWriting = true
+	return buffer, my.isScheduled
+}
+
+// endWrite sender写完pop()出来的数据之后调用
+func (my *sendingQueue) endWrite() {
+	my.lock.Lock()
+	my.isWriting = false
+	my.lock.Unlock()
+}
+
+// isEmpty 所有数据都已经写入了链接
+func (my *sendingQueue) isEmpty() bool {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	return my.count == 0 && !my.isWriting
+}
+
+// close session关闭或者close帧写出之后, 丢弃所有还没有发出去的数据, 之后的push()返回ErrSessionClosed
+func (my *sendingQueue) close() {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	my.isClosed = true
+	for level, items := range my.levels {
+		for _, item := range items {
+			releaseSendingItem(item)
+		}
+
+		my.levels[level] = nil
+	}
+
+	my.count = 0
+	my.size = 0
+}
+
+func releaseSendingItem(item sendingItem) {
+	// 所有PlayerConn在Write()返回后都不再引用data, 因此可以立即归还
+	if item.isPooled {
+		bufferpool.Put(item.data)
+	}
+}
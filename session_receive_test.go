@@ -0,0 +1,137 @@
+package road
+
+import (
+	"context"
+	"github.com/lixianmin/road/client"
+	"github.com/lixianmin/road/component"
+	"github.com/lixianmin/road/conn/message"
+	"strings"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// MemoryBlock Wait()一直阻塞到release被关闭或者ctx被取消, 并通过ctxChan交出handler的ctx
+type MemoryBlock struct {
+	ctxChan chan context.Context
+	release chan struct{}
+}
+
+func (my *MemoryBlock) Wait(ctx context.Context, request *MemoryEnterRequest) (*MemoryEnterResponse, error) {
+	my.ctxChan <- ctx
+	select {
+	case <-my.release:
+	case <-ctx.Done():
+	}
+
+	return &MemoryEnterResponse{Greeting: "late"}, nil
+}
+
+func newBlockClient(t *testing.T, opts ...AppOption) (*App, *MemoryBlock, *client.Client, Session) {
+	var app, accept = newTestApp(t, opts...)
+	var block = &MemoryBlock{ctxChan: make(chan context.Context, 1), release: make(chan struct{})}
+	if err := app.Register(block, component.WithName("block")); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var c, err = client.NewMemoryClient(accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Disconnect)
+
+	return app, block, c, <-sessionChan
+}
+
+// waitDone 等待handler的ctx被取消, 返回ctx.Err()
+func waitDone(t *testing.T, ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(3 * time.Second):
+		t.Fatal("handler ctx should be done")
+		return nil
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	var cases = []struct {
+		name      string
+		opts      []AppOption
+		isTimeout bool
+	}{
+		{name: "default timeout", opts: []AppOption{WithHandlerTimeout(50 * time.Millisecond)}, isTimeout: true},
+		{name: "route override", opts: []AppOption{WithHandlerTimeout(time.Hour), WithRouteTimeout("block.Wait", 50*time.Millisecond)}, isTimeout: true},
+		{name: "route unlimited", opts: []AppOption{WithHandlerTimeout(50 * time.Millisecond), WithRouteTimeout("block.Wait", 0)}},
+		{name: "other route", opts: []AppOption{WithRouteTimeout("room.Enter", 50*time.Millisecond)}},
+	}
+
+	for _, item := range cases {
+		var _, block, c, _ = newBlockClient(t, item.opts...)
+		var id, _ = c.SendRequest("block.Wait", []byte(`{}`))
+		var ctx = <-block.ctxChan
+
+		if !item.isTimeout {
+			// 没有超时的handler一直等到release才回复
+			time.Sleep(150 * time.Millisecond)
+			if ctx.Err() != nil {
+				t.Fatalf("%s: handler ctx should not be done, got %v", item.name, ctx.Err())
+			}
+
+			close(block.release)
+			var msg = receiveMessage(t, c)
+			if msg.Id != id || msg.Err || !strings.Contains(string(msg.Data), "late") {
+				t.Fatalf("%s: expect the handler result, got id=%d err=%v data=%s", item.name, msg.Id, msg.Err, msg.Data)
+			}
+			continue
+		}
+
+		var msg = receiveMessage(t, c)
+		if msg.Id != id || !msg.Err || !strings.Contains(string(msg.Data), ErrHandlerTimeout.Code) {
+			t.Fatalf("%s: expect ErrHandlerTimeout, got id=%d err=%v data=%s", item.name, msg.Id, msg.Err, msg.Data)
+		}
+
+		// handler的ctx在超时后被取消, 它迟到的结果被丢弃. handler在session的goroutine中执行, 因此下一条消息就是后面request的回复
+		if err := waitDone(t, ctx); err != context.DeadlineExceeded {
+			t.Fatalf("%s: expect context.DeadlineExceeded, got %v", item.name, err)
+		}
+
+		var next, _ = c.SendRequest("room.Enter", []byte(`{"name":"kitty"}`))
+		if msg = receiveMessage(t, c); msg.Type != message.Response || msg.Id != next || msg.Err {
+			t.Fatalf("%s: expect the response of room.Enter, got id=%d err=%v data=%s", item.name, msg.Id, msg.Err, msg.Data)
+		}
+	}
+}
+
+func TestHandlerCanceledOnSessionClose(t *testing.T) {
+	var _, block, c, session = newBlockClient(t)
+	_, _ = c.SendRequest("block.Wait", []byte(`{}`))
+	var ctx = <-block.ctxChan
+
+	_ = session.(*sessionWrapper).Close()
+	if err := waitDone(t, ctx); err != context.Canceled {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
+
+func TestHandlerCanceledOnAppClose(t *testing.T) {
+	var app, block, c, _ = newBlockClient(t)
+	_, _ = c.SendRequest("block.Wait", []byte(`{}`))
+	var ctx = <-block.ctxChan
+
+	_ = app.Close()
+	if err := waitDone(t, ctx); err != context.Canceled {
+		t.Fatalf("expect context.Canceled, got %v", err)
+	}
+}
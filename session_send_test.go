@@ -0,0 +1,98 @@
+package road
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/epoll"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// TestHttpKickPoll http链接被kick之后, client仍然可以poll到kick包, 确认之后才返回链接已经关闭
+func TestHttpKickPoll(t *testing.T) {
+	var mux = http.NewServeMux()
+	var app = NewApp(epoll.NewHttpAcceptor(mux, "/http"))
+	t.Cleanup(func() { _ = app.Close() })
+
+	var server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var response, err = http.Post(server.URL+"/http/open", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		Sid string `json:"sid"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var encoder = codec.NewPomeloPacketEncoder()
+	var handshake, _ = encoder.Encode(packet.Handshake, []byte(`{"sys":{"platform":"test"}}`))
+	var handshakeAck, _ = encoder.Encode(packet.HandshakeAck, nil)
+	response, err = http.Post(server.URL+"/http/send?sid="+result.Sid, "application/octet-stream", bytes.NewReader(append(handshake, handshakeAck...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+
+	var session = <-sessionChan
+	var closedChan = make(chan struct{})
+	session.OnClosed(func() {
+		close(closedChan)
+	})
+
+	if err := session.Kick(); err != nil {
+		t.Fatal(err)
+	}
+
+	// kick之后session会关闭链接, 等链接关闭之后再poll
+	select {
+	case <-closedChan:
+	case <-time.After(3 * time.Second):
+		t.Fatal("session should be closed after kick")
+	}
+
+	var poll = func(ack string) (int, string, string) {
+		var response, err = http.Get(server.URL + "/http/poll?sid=" + result.Sid + "&ack=" + ack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		var body, _ = io.ReadAll(response.Body)
+		return response.StatusCode, string(body), response.Header.Get("X-Road-Seq")
+	}
+
+	var status, body, seq = poll("0")
+	var packets, _ = codec.NewPomeloPacketDecoder().Decode([]byte(body))
+	if status != http.StatusOK || len(packets) == 0 || packets[len(packets)-1].Type != packet.Kick {
+		t.Fatalf("expect the kick packet, got status=%d packets=%d", status, len(packets))
+	}
+
+	if status, body, _ = poll(seq); status != http.StatusGone || !strings.Contains(body, "connection is closed") {
+		t.Fatalf("expect connection is closed after ack, got status=%d body=%q", status, body)
+	}
+}
@@ -3,6 +3,10 @@ package road
 import (
 	"github.com/lixianmin/got/loom"
 	"github.com/lixianmin/logo"
+	"github.com/lixianmin/road/epoll"
+	"github.com/lixianmin/road/util/bufferpool"
+	"sync"
+	"time"
 )
 
 /********************************************************************
@@ -23,39 +27,184 @@ Copyright (C) - All Rights Reserved
 *********************************************************************/
 
 type sendingItem struct {
-	session *sessionImpl
-	data    []byte
+	session  *sessionImpl
+	data     []byte
+	priority Priority
+	isPooled bool // data来自bufferpool, 写完之后归还
+	isClose  bool // 发送close帧，用于websocket的close握手
 }
 
+// sessionSender 每个session的数据先进入自己的sendingQueue, sender只维护有数据待发送的session列表,
+// 因此入队永远不会阻塞, 一个慢client也不会影响同一个sender上的其它session
 type sessionSender struct {
-	sendingChan chan sendingItem
+	lock          sync.Mutex
+	readySessions []*sessionImpl // 有数据待发送的session
+	signal        chan struct{}  // readySessions从空变为非空时通知goLoop()
+	maxBatchSize  int            // 每个session每一轮最多合并的字节数
+	maxDelay      time.Duration  // 收到通知后等待多久再写, 为0时只合并已经排队的数据
+
+	processing []*sessionImpl // 与readySessions交替使用, 减少分配
+	items      []sendingItem
 }
 
-func newSessionSender(chanSize int) *sessionSender {
+func newSessionSender(options appOptions) *sessionSender {
 	var my = &sessionSender{
-		sendingChan: make(chan sendingItem, chanSize),
+		signal:       make(chan struct{}, 1),
+		maxBatchSize: options.SenderMaxBatchSize,
+		maxDelay:     options.SenderMaxDelay,
 	}
 
 	loom.Go(my.goLoop)
 	return my
 }
 
+// schedule 把session加入待发送列表, 由sendingQueue保证同一个session不会被重复加入
+func (my *sessionSender) schedule(session *sessionImpl) {
+	my.lock.Lock()
+	my.readySessions = append(my.readySessions, session)
+	my.lock.Unlock()
+
+	select {
+	case my.signal <- struct{}{}:
+	default:
+	}
+}
+
 func (my *sessionSender) goLoop(later loom.Later) {
+	// 用一点延迟换取更少的系统调用与websocket帧: 收到通知后启动timer, 到期前的通知都合并到同一轮写入中
+	var delayTimer = time.NewTimer(time.Hour)
+	delayTimer.Stop()
+	var delayChan <-chan time.Time
+
 	for {
 		select {
-		case item := <-my.sendingChan:
-			my.onWriteBytes(item.session, item.data)
+		case <-my.signal:
+			if my.maxDelay <= 0 {
+				my.onFlush()
+			} else if delayChan == nil {
+				delayTimer.Reset(my.maxDelay)
+				delayChan = delayTimer.C
+			}
+		case <-delayChan:
+			delayChan = nil
+			my.onFlush()
+		}
+	}
+}
+
+func (my *sessionSender) onFlush() {
+	my.lock.Lock()
+	var sessions = my.readySessions
+	my.readySessions = my.processing[:0]
+	my.lock.Unlock()
+
+	for i, session := range sessions {
+		my.onWriteSession(session)
+		sessions[i] = nil
+	}
+
+	my.processing = sessions
+}
+
+// onWriteSession 按优先级取出同一个session的数据, 连续的多个pomelo packet合并为一次Write(), tcp是一次系统调用, websocket是一个帧,
+// 解码端本来就支持一次收到多个packet. close帧是一个分界点, 它之前的数据必须先写出去
+func (my *sessionSender) onWriteSession(session *sessionImpl) {
+	var items, hasMore = session.queue.pop(my.maxBatchSize, my.items[:0])
+	var start = 0
+	for i, item := range items {
+		if item.isClose {
+			my.onWriteItems(session, items[start:i])
+			my.onWriteClose(session)
+
+			// close帧之后不能再发送数据帧. 控制数据优先发送, 因此被kick之前排队的低优先级数据可能在这里被丢弃;
+			// 队列也一起关闭, 丢弃还在排队的数据, 之后也不再接受新数据
+			for _, rest := range items[i+1:] {
+				releaseSendingItem(rest)
+			}
+
+			session.queue.close()
+			start = len(items)
+			hasMore = false
+			break
 		}
 	}
+
+	my.onWriteItems(session, items[start:])
+
+	for i := range items {
+		items[i] = sendingItem{}
+	}
+	my.items = items[:0]
+	session.queue.endWrite()
+
+	// 超出maxBatchSize的部分留到下一轮, 避免一个session长时间占用sender
+	if hasMore {
+		my.schedule(session)
+	}
 }
 
-func (my *sessionSender) onWriteBytes(session *sessionImpl, data []byte) {
+func (my *sessionSender) onWriteItems(session *sessionImpl, items []sendingItem) {
+	switch len(items) {
+	case 0:
+		return
+	case 1:
+		// 池化的data直接交给链接, 由链接负责归还
+		var item = items[0]
+		my.onWriteBytes(session, item.data, item.isPooled)
+		return
+	}
+
+	var size = 0
+	for _, item := range items {
+		size += len(item.data)
+	}
+
+	var data = bufferpool.Get(size)[:0]
+	for _, item := range items {
+		data = append(data, item.data...)
+		releaseSendingItem(item)
+	}
+
+	my.onWriteBytes(session, data, true)
+}
+
+// onWriteBytes isPooled为true时data来自bufferpool, 它的所有权交给这个方法
+func (my *sessionSender) onWriteBytes(session *sessionImpl, data []byte, isPooled bool) {
 	select {
 	case <-session.wc.C():
+		if isPooled {
+			bufferpool.Put(data)
+		}
 	default:
-		if _, err := session.conn.Write(data); err != nil {
+		var err error
+		if isPooled {
+			err = epoll.WritePooledBuffer(session.conn, data)
+		} else {
+			_, err = session.conn.Write(data)
+		}
+
+		if err != nil {
 			logo.Info("close session(%d) by onWriteBytes(), err=%q", session.id, err)
-			_ = session.Close()
+			_ = session.closeWithReason(err)
+		}
+	}
+}
+
+func (my *sessionSender) onWriteClose(session *sessionImpl) {
+	select {
+	case <-session.wc.C():
+	default:
+		if _, ok := session.conn.(epoll.CloseWriter); !ok {
+			// 没有close握手的链接(比如tcp), 不能阻塞sender, 因此在单独的goroutine中等kick包写完
+			loom.Go(func(later loom.Later) {
+				session.closeAfterFlush(kickFlushTimeout)
+			})
+			return
+		}
+
+		if err := epoll.WriteNormalClose(session.conn, "kicked"); err != nil {
+			logo.Info("close session(%d) by onWriteClose(), err=%q", session.id, err)
+			_ = session.closeWithReason(err)
 		}
 	}
 }
@@ -0,0 +1,184 @@
+package road
+
+import (
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/epoll"
+	"sync"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// recordConn 记录写出的数据与close帧, 其它方法由嵌入的PlayerConn提供(这里不会被调用)
+type recordConn struct {
+	epoll.PlayerConn
+	lock   sync.Mutex
+	writes [][]byte
+	closes int
+}
+
+func (my *recordConn) Write(b []byte) (int, error) {
+	my.lock.Lock()
+	my.writes = append(my.writes, append([]byte(nil), b...))
+	my.lock.Unlock()
+	return len(b), nil
+}
+
+func (my *recordConn) WriteClose(code int, reason string) error {
+	my.lock.Lock()
+	my.closes++
+	my.lock.Unlock()
+	return nil
+}
+
+// countPackets 统计已经写出的Write()次数, 以及其中type类型的packet数
+func (my *recordConn) countPackets(typ packet.Type) (int, int) {
+	my.lock.Lock()
+	defer my.lock.Unlock()
+
+	var count = 0
+	for _, data := range my.writes {
+		var packets, _ = codec.NewPomeloPacketDecoder().Decode(data)
+		for _, p := range packets {
+			if p.Type == typ {
+				count++
+			}
+		}
+	}
+
+	return len(my.writes), count
+}
+
+// recordAcceptor 把MemoryAcceptor的链接包装为recordConn, 读取仍然走原来的链接
+type recordAcceptor struct {
+	connChan   chan epoll.PlayerConn
+	recordChan chan *recordConn
+}
+
+func newRecordAcceptor(accept *epoll.MemoryAcceptor) *recordAcceptor {
+	var my = &recordAcceptor{
+		connChan:   make(chan epoll.PlayerConn, 1),
+		recordChan: make(chan *recordConn, 1),
+	}
+
+	go func() {
+		for conn := range accept.GetConnChan() {
+			var record = &recordConn{PlayerConn: conn}
+			my.connChan <- record
+			my.recordChan <- record
+		}
+	}()
+
+	return my
+}
+
+func (my *recordAcceptor) GetConnChan() chan epoll.PlayerConn {
+	return my.connChan
+}
+
+// TestSenderCoalescesPushes maxDelay之内排队的多个push合并为一次Write()
+func TestSenderCoalescesPushes(t *testing.T) {
+	var accept = epoll.NewMemoryAcceptor()
+	var record = newRecordAcceptor(accept)
+	var app = NewApp(record, WithSenderCount(1), WithSenderMaxDelay(100*time.Millisecond))
+	t.Cleanup(func() { _ = app.Close() })
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var conn, err = accept.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var encoder = codec.NewPomeloPacketEncoder()
+	var handshake, _ = encoder.Encode(packet.Handshake, []byte(`{"sys":{"platform":"test"}}`))
+	var ack, _ = encoder.Encode(packet.HandshakeAck, nil)
+	if _, err := conn.Write(append(handshake, ack...)); err != nil {
+		t.Fatal(err)
+	}
+
+	var session = <-sessionChan
+	var recordConn = <-record.recordChan
+
+	// 等handshake的回复写出去之后再开始计数
+	var waitWrites = func(typ packet.Type, expect int) int {
+		var deadline = time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if writes, count := recordConn.countPackets(typ); count >= expect {
+				return writes
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		t.Fatalf("timeout waiting for %d packets of type %d", expect, typ)
+		return 0
+	}
+
+	var baseWrites = waitWrites(packet.Handshake, 1)
+
+	const pushCount = 10
+	for i := 0; i < pushCount; i++ {
+		if err := session.Push("room.onChat", &MemoryEnterResponse{Greeting: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if writes := waitWrites(packet.Data, pushCount) - baseWrites; writes != 1 {
+		t.Fatalf("expect %d pushes in 1 Write(), got %d writes", pushCount, writes)
+	}
+}
+
+func TestCheckPushPriority(t *testing.T) {
+	var cases = []struct {
+		priority Priority
+		expect   Priority
+	}{
+		{priority: -1, expect: PriorityPushHigh},
+		{priority: PriorityControl, expect: PriorityPushHigh},
+		{priority: PriorityResponse, expect: PriorityResponse},
+		{priority: PriorityPushLow, expect: PriorityPushLow},
+		{priority: priorityCount, expect: PriorityPushHigh},
+	}
+
+	for _, item := range cases {
+		if priority := checkPushPriority(item.priority); priority != item.expect {
+			t.Errorf("checkPushPriority(%d)=%d, expect %d", item.priority, priority, item.expect)
+		}
+	}
+}
+
+// TestSenderStopsAfterClose close帧写出之后不再调度这个session, 也不再接受新数据
+func TestSenderStopsAfterClose(t *testing.T) {
+	var conn = &recordConn{}
+	var session = &sessionImpl{conn: conn, queue: &sendingQueue{}}
+	var sender = &sessionSender{signal: make(chan struct{}, 1), maxBatchSize: 1}
+
+	_, _ = session.queue.push(sendingItem{session: session, priority: PriorityControl, isClose: true})
+	for i := 0; i < 3; i++ {
+		_, _ = session.queue.push(sendingItem{session: session, data: []byte("push"), priority: PriorityPushHigh})
+	}
+
+	sender.onWriteSession(session)
+	if conn.closes != 1 || len(conn.writes) != 0 {
+		t.Fatalf("expect only the close frame, got closes=%d writes=%d", conn.closes, len(conn.writes))
+	}
+
+	if len(sender.readySessions) != 0 {
+		t.Fatal("session should not be rescheduled after the close frame")
+	}
+
+	if _, err := session.queue.push(sendingItem{session: session, data: []byte("late"), priority: PriorityPushHigh}); err != ErrSessionClosed {
+		t.Fatalf("expect ErrSessionClosed, got err=%v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package road
+
+import (
+	"context"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/lixianmin/road/conn/codec"
+	"github.com/lixianmin/road/conn/packet"
+	"github.com/lixianmin/road/epoll"
+	"github.com/xtaci/gaio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+通过真实的tcp与websocket链接测试WithMaxPendingWriteBytes()与WithWriteDeadline(): client不读数据时, 数据积压在gaio中
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+// dialWriteSession 通过kind类型的acceptor建立链接并完成握手, 返回server端的session与client端的链接
+func dialWriteSession(t *testing.T, kind string, opts ...epoll.AcceptorOption) (*sessionWrapper, net.Conn) {
+	var accept epoll.Acceptor
+	var dial func() (net.Conn, error)
+	var writePacket = func(conn net.Conn, data []byte) error {
+		var _, err = conn.Write(data)
+		return err
+	}
+
+	switch kind {
+	case epoll.KindTcp:
+		var listener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var address = listener.Addr().String()
+		_ = listener.Close()
+
+		accept = epoll.NewTcpAcceptor(address, opts...)
+		dial = func() (net.Conn, error) {
+			// 等待acceptor开始监听
+			for i := 0; ; i++ {
+				var conn, err = net.Dial("tcp", address)
+				if err == nil || i == 100 {
+					return conn, err
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	case epoll.KindWs:
+		var mux = http.NewServeMux()
+		accept = epoll.NewWsAcceptor(mux, "/ws", opts...)
+		var server = httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+
+		dial = func() (net.Conn, error) {
+			var url = "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+			var conn, _, _, err = ws.Dial(context.Background(), url)
+			return conn, err
+		}
+		writePacket = func(conn net.Conn, data []byte) error {
+			return wsutil.WriteClientBinary(conn, data)
+		}
+	}
+
+	var app = NewApp(accept, WithSessionRateLimitBySecond(1000000), WithSessionQueueLimit(0, 0))
+	t.Cleanup(func() { _ = app.Close() })
+
+	var sessionChan = make(chan Session, 1)
+	onHandShakenSync(app, func(session Session) {
+		sessionChan <- session
+	})
+
+	var conn, err = dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var encoder = codec.NewPomeloPacketEncoder()
+	var handshake, _ = encoder.Encode(packet.Handshake, []byte(`{"sys":{"platform":"test"}}`))
+	var ack, _ = encoder.Encode(packet.HandshakeAck, nil)
+	for _, data := range [][]byte{handshake, ack} {
+		if err := writePacket(conn, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case session := <-sessionChan:
+		return session.(*sessionWrapper), conn
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for session")
+		return nil, nil
+	}
+}
+
+// pushUntilClosed client不读数据, 一直推送直到session被关闭, 返回关闭的原因
+func pushUntilClosed(t *testing.T, session *sessionWrapper) error {
+	var response = &MemoryEnterResponse{Greeting: strings.Repeat("x", 64*1024)}
+	var timeout = time.After(10 * time.Second)
+	for {
+		_ = session.Push("room.onChat", response)
+		select {
+		case <-session.wc.C():
+			return session.CloseReason()
+		case <-timeout:
+			t.Fatalf("session should be closed, pending=%d", epoll.GetPendingWriteBytes(session.conn))
+			return nil
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestMaxPendingWriteBytes(t *testing.T) {
+	for _, kind := range []string{epoll.KindTcp, epoll.KindWs} {
+		var session, _ = dialWriteSession(t, kind, epoll.WithMaxPendingWriteBytes(256*1024))
+		if reason := pushUntilClosed(t, session); reason != epoll.ErrTooManyPendingBytes {
+			t.Errorf("%s: expect ErrTooManyPendingBytes, got %v", kind, reason)
+		}
+	}
+}
+
+func TestWriteDeadline(t *testing.T) {
+	for _, kind := range []string{epoll.KindTcp, epoll.KindWs} {
+		var session, _ = dialWriteSession(t, kind, epoll.WithWriteDeadline(200*time.Millisecond))
+		if reason := pushUntilClosed(t, session); !errors.Is(reason, gaio.ErrDeadline) {
+			t.Errorf("%s: expect gaio.ErrDeadline, got %v", kind, reason)
+		}
+	}
+}
+
+// TestPendingWriteBytesDrained client读完所有数据之后, 积压的字节数回到0
+func TestPendingWriteBytesDrained(t *testing.T) {
+	for _, kind := range []string{epoll.KindTcp, epoll.KindWs} {
+		var session, conn = dialWriteSession(t, kind, epoll.WithMaxPendingWriteBytes(64*1024*1024), epoll.WithWriteDeadline(3*time.Second))
+		var response = &MemoryEnterResponse{Greeting: strings.Repeat("x", 64*1024)}
+		for i := 0; i < 32; i++ {
+			if err := session.Push("room.onChat", response); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var received int64
+		go func() {
+			var buffer = make([]byte, 64*1024)
+			for {
+				var n, err = conn.Read(buffer)
+				atomic.AddInt64(&received, int64(n))
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		var deadline = time.Now().Add(3 * time.Second)
+		for atomic.LoadInt64(&received) < 32*64*1024 || epoll.GetPendingWriteBytes(session.conn) != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: pending bytes should go back to 0, got %d", kind, epoll.GetPendingWriteBytes(session.conn))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if reason := session.CloseReason(); reason != nil {
+			t.Fatalf("%s: session should not be closed, reason=%v", kind, reason)
+		}
+	}
+}
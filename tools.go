@@ -13,12 +13,13 @@ author:     lixianmin
 Copyright (C) - All Rights Reserved
 *********************************************************************/
 
+// GetSessionFromCtx Deprecated: 请使用SessionFromContext(), 它返回Session接口, 并且找不到时不打印警告日志
 func GetSessionFromCtx(ctx context.Context) *sessionImpl {
-	fetus := ctx.Value(ifs.CtxKeySession)
-	if fetus == nil {
+	fetus, ok := ctx.Value(ifs.CtxKeySession).(*sessionWrapper)
+	if !ok {
 		logo.Warn("ctx doesn't contain the session")
 		return nil
 	}
 
-	return fetus.(*sessionImpl)
+	return fetus.sessionImpl
 }
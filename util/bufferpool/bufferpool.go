@@ -0,0 +1,93 @@
+package bufferpool
+
+import (
+	"sync"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+按2的幂分级的[]byte池, 用于收发路径上的帧与packet.
+
+没有使用sync.Pool, 因为把[]byte放回sync.Pool时需要装箱成interface{}, 每次Put()都会产生一次分配;
+这里每一级是一个带锁的栈, 并且按字节数限制每一级最多保留的buffer数, 防止流量高峰之后一直占着内存
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const (
+	minShift         = 6  // 最小的一级是64字节
+	maxShift         = 16 // 最大的一级是64KB, 更大的buffer直接分配, 不进池
+	maxRetainedBytes = 4 << 20
+)
+
+type sizeClass struct {
+	lock    sync.Mutex
+	buffers [][]byte
+	limit   int
+}
+
+var classes = newClasses()
+
+func newClasses() []*sizeClass {
+	var list = make([]*sizeClass, maxShift-minShift+1)
+	for i := range list {
+		var size = 1 << uint(minShift+i)
+		list[i] = &sizeClass{limit: maxRetainedBytes / size}
+	}
+
+	return list
+}
+
+// Get 返回一个len(buf)==size的buffer, 内容是未初始化的. 用完之后应该调用Put()归还
+func Get(size int) []byte {
+	var index = classIndex(size)
+	if index < 0 {
+		return make([]byte, size)
+	}
+
+	var class = classes[index]
+	class.lock.Lock()
+	var last = len(class.buffers) - 1
+	if last >= 0 {
+		var buf = class.buffers[last]
+		class.buffers[last] = nil
+		class.buffers = class.buffers[:last]
+		class.lock.Unlock()
+		return buf[:size]
+	}
+	class.lock.Unlock()
+
+	return make([]byte, size, 1<<uint(minShift+index))
+}
+
+// Put 归还由Get()得到的buffer, 归还之后调用方不能再使用它. cap()不是分级大小的buffer会被直接丢弃
+func Put(buf []byte) {
+	var size = cap(buf)
+	var index = classIndex(size)
+	if index < 0 || size != 1<<uint(minShift+index) {
+		return
+	}
+
+	var class = classes[index]
+	class.lock.Lock()
+	if len(class.buffers) < class.limit {
+		class.buffers = append(class.buffers, buf[:0])
+	}
+	class.lock.Unlock()
+}
+
+// classIndex 返回能容纳size字节的最小一级, 超出最大一级时返回-1
+func classIndex(size int) int {
+	if size > 1<<maxShift {
+		return -1
+	}
+
+	var index = 0
+	for size > 1<<uint(minShift+index) {
+		index++
+	}
+
+	return index
+}
@@ -38,6 +38,22 @@ func PCall(method reflect.Method, args []reflect.Value) (rets interface{}, err e
 	return
 }
 
+// PInvoke calls a typed handler without reflection and recovers in case of panic, it behaves the same as PCall
+func PInvoke(name string, invoke func() (interface{}, error), isNotify bool) (rets interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logo.Error("route=%s, recover=%v", name, rec)
+		}
+	}()
+
+	rets, err = invoke()
+	if err == nil && rets == nil && !isNotify {
+		err = ifs.ErrReplyShouldBeNotNull
+	}
+
+	return
+}
+
 // SerializeOrRaw serializes the interface if its not an array of bytes already
 func SerializeOrRaw(serializer serialize.Serializer, v interface{}) ([]byte, error) {
 	if data, ok := v.([]byte); ok {
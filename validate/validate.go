@@ -0,0 +1,439 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+根据struct tag校验handler的参数, 比如:
+
+	type EnterRequest struct {
+		Name  string `json:"name" validate:"required,min=2,max=16"`
+		Level int    `json:"level" validate:"min=1,max=100"`
+		Color string `json:"color" validate:"oneof=red green blue"`
+		Code  string `json:"code" validate:"len=6,regexp=^[0-9]+$"`
+	}
+
+支持的规则:
+1. required: 不能是零值, 指针不能是nil
+2. min=n, max=n: 数字比较大小; string, slice, map比较长度, string按字符数计算
+3. len=n: string, slice, map的长度必须等于n
+4. regexp=pattern: string必须匹配pattern. pattern中可能有逗号, 因此regexp必须是最后一条规则
+5. oneof=a b c: string或整数必须是其中之一
+
+指针为nil时只检查required; 嵌套的struct(或者struct指针)会递归校验, 字段名优先使用json tag中的名字
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+const tagName = "validate"
+
+// FieldError 一个字段的校验错误, 会序列化后返回给client
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors 一次校验中所有字段的错误
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	var items = make([]string, 0, len(errs))
+	for _, item := range errs {
+		items = append(items, item.Field+": "+item.Message)
+	}
+
+	return strings.Join(items, "; ")
+}
+
+type (
+	check struct {
+		rule    string
+		message string
+		isValid func(v reflect.Value) bool
+	}
+
+	fieldRules struct {
+		index    int
+		name     string
+		required bool
+		checks   []check
+		nested   *structRules // 嵌套的struct, 没有需要校验的字段时为nil
+	}
+
+	structRules struct {
+		fields     []fieldRules
+		err        error // tag写错时的错误, 每次校验都返回它
+		isCompiled bool  // 为false时还在编译中, 自引用的类型此时拿到的fields还不完整
+	}
+)
+
+var (
+	compiled sync.Map // 编译完成的*structRules, 校验时不加锁读取
+	lock     sync.Mutex
+)
+
+// Compile 提前编译typ(struct或者struct指针)的校验规则, 返回tag中的错误, 其它类型直接返回nil.
+// 在注册handler时调用, 这样tag写错在启动时就能发现, 而不是等到第一个请求
+func Compile(typ reflect.Type) error {
+	if typ == nil {
+		return nil
+	}
+
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return getStructRules(typ).err
+}
+
+// Struct 校验v中所有带validate tag的字段, v可以是struct或者struct指针, 其它类型直接返回nil.
+// 校验失败时返回Errors, tag写错时返回普通的error
+func Struct(v interface{}) error {
+	var value = reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var rules = getStructRules(value.Type())
+	if rules.err != nil {
+		return rules.err
+	}
+
+	var errs Errors
+	rules.validate(value, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func getStructRules(typ reflect.Type) *structRules {
+	if rules, ok := compiled.Load(typ); ok {
+		return rules.(*structRules)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if rules, ok := compiled.Load(typ); ok {
+		return rules.(*structRules)
+	}
+
+	// 本轮编译的所有类型先放在pending中, 全部成功之后才发布到compiled. 任何一个类型出错时只发布typ(带着err),
+	// 其它类型以后单独编译, 这样不会有类型引用到编译了一半或者出错的rules
+	var pending = make(map[reflect.Type]*structRules)
+	var rules = compileStruct(typ, pending)
+	if rules.err != nil {
+		compiled.Store(typ, rules)
+		return rules
+	}
+
+	for key, item := range pending {
+		compiled.Store(key, item)
+	}
+
+	return rules
+}
+
+// compileStruct 调用方需要持有lock. 先把rules放入pending再编译字段, 这样自引用的类型不会无限递归
+func compileStruct(typ reflect.Type, pending map[reflect.Type]*structRules) *structRules {
+	if rules, ok := compiled.Load(typ); ok {
+		return rules.(*structRules)
+	}
+
+	if rules, ok := pending[typ]; ok {
+		return rules
+	}
+
+	var rules = &structRules{}
+	pending[typ] = rules
+
+	for i := 0; i < typ.NumField(); i++ {
+		var field = typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		var item, err = compileField(field, pending)
+		if err != nil {
+			rules.err = fmt.Errorf("validate: %s.%s: %s", typ.Name(), field.Name, err.Error())
+			rules.fields = nil
+			break
+		}
+
+		if item.required || len(item.checks) > 0 || item.nested != nil {
+			item.index = i
+			rules.fields = append(rules.fields, item)
+		}
+	}
+
+	rules.isCompiled = true
+	return rules
+}
+
+func compileField(field reflect.StructField, pending map[reflect.Type]*structRules) (fieldRules, error) {
+	var item = fieldRules{name: getFieldName(field)}
+	var typ = field.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() == reflect.Struct {
+		// 与json一样, 嵌入的struct的字段名不带前缀
+		if field.Anonymous {
+			item.name = ""
+		}
+
+		var nested = compileStruct(typ, pending)
+		if nested.err != nil {
+			return item, nested.err
+		}
+
+		// 还在编译中的类型(自引用)现在看不出有没有需要校验的字段, 因此也要保留
+		if len(nested.fields) > 0 || !nested.isCompiled {
+			item.nested = nested
+		}
+	}
+
+	var tag = field.Tag.Get(tagName)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regexp=") {
+			rule, tag = tag, ""
+		} else if index := strings.IndexByte(tag, ','); index >= 0 {
+			rule, tag = tag[:index], tag[index+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		if rule == "required" {
+			item.required = true
+			continue
+		}
+
+		var c, err = compileCheck(typ, rule)
+		if err != nil {
+			return item, err
+		}
+
+		item.checks = append(item.checks, c)
+	}
+
+	return item, nil
+}
+
+func compileCheck(typ reflect.Type, rule string) (check, error) {
+	var name, param = rule, ""
+	if index := strings.IndexByte(rule, '='); index >= 0 {
+		name, param = rule[:index], rule[index+1:]
+	}
+
+	switch name {
+	case "min", "max":
+		return compileRange(typ, name, param)
+	case "len":
+		var n, err = strconv.Atoi(param)
+		if err != nil || !hasLength(typ.Kind()) {
+			return check{}, fmt.Errorf("invalid rule %q for %s", rule, typ)
+		}
+
+		return check{rule: name, message: fmt.Sprintf("length must be %d", n), isValid: func(v reflect.Value) bool {
+			return getLength(v) == n
+		}}, nil
+	case "regexp":
+		var pattern, err = regexp.Compile(param)
+		if err != nil || typ.Kind() != reflect.String {
+			return check{}, fmt.Errorf("invalid rule %q for %s", rule, typ)
+		}
+
+		return check{rule: name, message: fmt.Sprintf("must match %s", param), isValid: func(v reflect.Value) bool {
+			return pattern.MatchString(v.String())
+		}}, nil
+	case "oneof":
+		return compileOneOf(typ, param)
+	default:
+		return check{}, fmt.Errorf("unknown rule %q", rule)
+	}
+}
+
+func compileRange(typ reflect.Type, name string, param string) (check, error) {
+	var limit, err = strconv.ParseFloat(param, 64)
+	if err != nil {
+		return check{}, fmt.Errorf("invalid rule %s=%s", name, param)
+	}
+
+	var isMin = name == "min"
+	var compare = func(value float64) bool {
+		if isMin {
+			return value >= limit
+		}
+
+		return value <= limit
+	}
+
+	var relation = "at most"
+	if isMin {
+		relation = "at least"
+	}
+
+	var kind = typ.Kind()
+	switch {
+	case hasLength(kind):
+		return check{rule: name, message: fmt.Sprintf("length must be %s %s", relation, param), isValid: func(v reflect.Value) bool {
+			return compare(float64(getLength(v)))
+		}}, nil
+	case isNumber(kind):
+		return check{rule: name, message: fmt.Sprintf("must be %s %s", relation, param), isValid: func(v reflect.Value) bool {
+			return compare(getNumber(v))
+		}}, nil
+	default:
+		return check{}, fmt.Errorf("invalid rule %s=%s for %s", name, param, typ)
+	}
+}
+
+func compileOneOf(typ reflect.Type, param string) (check, error) {
+	var values = strings.Fields(param)
+	var kind = typ.Kind()
+	if len(values) == 0 || (kind != reflect.String && !isInteger(kind)) {
+		return check{}, fmt.Errorf("invalid rule oneof=%s for %s", param, typ)
+	}
+
+	var set = make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+
+	return check{rule: "oneof", message: fmt.Sprintf("must be one of [%s]", param), isValid: func(v reflect.Value) bool {
+		var text string
+		switch {
+		case v.Kind() == reflect.String:
+			text = v.String()
+		case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+			text = strconv.FormatUint(v.Uint(), 10)
+		default:
+			text = strconv.FormatInt(v.Int(), 10)
+		}
+
+		var _, ok = set[text]
+		return ok
+	}}, nil
+}
+
+func (my *structRules) validate(value reflect.Value, prefix string, errs *Errors) {
+	for _, item := range my.fields {
+		var field = value.Field(item.index)
+		var name = joinFieldName(prefix, item.name)
+
+		if item.required && field.IsZero() {
+			*errs = append(*errs, FieldError{Field: name, Rule: "required", Message: "is required"})
+			continue
+		}
+
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				break
+			}
+
+			field = field.Elem()
+		}
+
+		if field.Kind() == reflect.Ptr {
+			continue
+		}
+
+		for _, c := range item.checks {
+			if !c.isValid(field) {
+				*errs = append(*errs, FieldError{Field: name, Rule: c.rule, Message: c.message})
+			}
+		}
+
+		if item.nested != nil {
+			item.nested.validate(field, name, errs)
+		}
+	}
+}
+
+func getFieldName(field reflect.StructField) string {
+	var tag = field.Tag.Get("json")
+	if index := strings.IndexByte(tag, ','); index >= 0 {
+		tag = tag[:index]
+	}
+
+	if tag != "" && tag != "-" {
+		return tag
+	}
+
+	return field.Name
+}
+
+func joinFieldName(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	if name == "" {
+		return prefix
+	}
+
+	return prefix + "." + name
+}
+
+func hasLength(kind reflect.Kind) bool {
+	return kind == reflect.String || kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array
+}
+
+func getLength(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+
+	return v.Len()
+}
+
+func isInteger(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Uintptr
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return isInteger(kind) || kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func getNumber(v reflect.Value) float64 {
+	switch {
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return float64(v.Int())
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
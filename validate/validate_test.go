@@ -0,0 +1,185 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/********************************************************************
+created:    2026-10-19
+author:     lixianmin
+
+Copyright (C) - All Rights Reserved
+*********************************************************************/
+
+type (
+	testRequired struct {
+		Name  string  `json:"name" validate:"required"`
+		Extra *string `validate:"required"`
+	}
+
+	testRange struct {
+		Level int       `json:"level" validate:"min=1,max=100"`
+		Rate  float64   `json:"rate" validate:"max=0.5"`
+		Name  string    `json:"name" validate:"min=2,max=4"`
+		Items []int     `json:"items" validate:"max=2"`
+		Count *uint8    `json:"count" validate:"min=3"`
+		Tags  [3]string `json:"tags" validate:"len=3"`
+	}
+
+	testLen struct {
+		Code  string         `json:"code" validate:"len=3"`
+		Pairs map[string]int `json:"pairs" validate:"len=1"`
+	}
+
+	testRegexp struct {
+		Code string `json:"code" validate:"len=4,regexp=^[0-9,]+$"`
+	}
+
+	testOneOf struct {
+		Color string `json:"color" validate:"oneof=red green"`
+		Level int    `json:"level" validate:"oneof=1 2 3"`
+		Flag  uint   `json:"flag" validate:"oneof=7"`
+	}
+
+	testInner struct {
+		Value int `json:"value" validate:"min=1"`
+	}
+
+	testNested struct {
+		Inner testInner  `json:"inner"`
+		Ptr   *testInner `json:"ptr"`
+		testInner
+	}
+
+	testNode struct {
+		Name string    `json:"name" validate:"required"`
+		Next *testNode `json:"next"`
+	}
+
+	testLeft struct {
+		Right *testRight `json:"right"`
+		Bad   string     `validate:"min=x"`
+	}
+
+	testRight struct {
+		Left *testLeft `json:"left"`
+		Name string    `json:"name" validate:"required"`
+	}
+)
+
+func TestStruct(t *testing.T) {
+	var text = "abc"
+	var small = uint8(2)
+	var cases = []struct {
+		name   string
+		value  interface{}
+		fields []string // 期望出错的字段, 格式为field:rule
+	}{
+		{name: "required ok", value: &testRequired{Name: "a", Extra: &text}},
+		{name: "required", value: &testRequired{}, fields: []string{"name:required", "Extra:required"}},
+		{name: "range ok", value: &testRange{Level: 1, Rate: 0.5, Name: "中文", Items: []int{1}}},
+		{name: "range too small", value: &testRange{Level: 0, Name: "a", Count: &small}, fields: []string{"level:min", "name:min", "count:min"}},
+		{name: "range too large", value: &testRange{Level: 101, Rate: 0.6, Name: "abcde", Items: []int{1, 2, 3}}, fields: []string{"level:max", "rate:max", "name:max", "items:max"}},
+		{name: "len ok", value: &testLen{Code: "abc", Pairs: map[string]int{"a": 1}}},
+		{name: "len", value: &testLen{Code: "ab"}, fields: []string{"code:len", "pairs:len"}},
+		{name: "regexp ok", value: &testRegexp{Code: "1,23"}},
+		{name: "regexp", value: &testRegexp{Code: "1a23"}, fields: []string{"code:regexp"}},
+		{name: "oneof ok", value: &testOneOf{Color: "red", Level: 3, Flag: 7}},
+		{name: "oneof", value: &testOneOf{Color: "blue", Level: 4, Flag: 1}, fields: []string{"color:oneof", "level:oneof", "flag:oneof"}},
+		{name: "nested", value: &testNested{Ptr: &testInner{}}, fields: []string{"inner.value:min", "ptr.value:min", "value:min"}},
+		{name: "nil pointer", value: &testNested{Inner: testInner{Value: 1}, testInner: testInner{Value: 1}}},
+		{name: "recursive", value: &testNode{Name: "a", Next: &testNode{Next: &testNode{}}}, fields: []string{"next.name:required", "next.next.name:required"}},
+		{name: "not a struct", value: 1},
+		{name: "nil", value: (*testRequired)(nil)},
+	}
+
+	for _, item := range cases {
+		var err = Struct(item.value)
+		var fields []string
+		if errs, ok := err.(Errors); ok {
+			for _, e := range errs {
+				fields = append(fields, e.Field+":"+e.Rule)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected err=%v", item.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(fields, item.fields) {
+			t.Errorf("%s: fields=%v, expect %v", item.name, fields, item.fields)
+		}
+	}
+}
+
+func TestCompileInvalidTags(t *testing.T) {
+	var cases = []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "unknown rule", value: struct {
+			Name string `validate:"email"`
+		}{}},
+		{name: "bad min", value: struct {
+			Level int `validate:"min=x"`
+		}{}},
+		{name: "min on bool", value: struct {
+			Flag bool `validate:"min=1"`
+		}{}},
+		{name: "len on int", value: struct {
+			Level int `validate:"len=1"`
+		}{}},
+		{name: "bad regexp", value: struct {
+			Code string `validate:"regexp=["`
+		}{}},
+		{name: "regexp on int", value: struct {
+			Code int `validate:"regexp=^1$"`
+		}{}},
+		{name: "empty oneof", value: struct {
+			Color string `validate:"oneof="`
+		}{}},
+		{name: "oneof on float", value: struct {
+			Rate float64 `validate:"oneof=1 2"`
+		}{}},
+		{name: "nested", value: struct {
+			Inner struct {
+				Level int `validate:"max=y"`
+			}
+		}{}},
+	}
+
+	for _, item := range cases {
+		if err := Compile(reflect.TypeOf(item.value)); err == nil {
+			t.Errorf("%s: expect an error", item.name)
+		}
+
+		if _, ok := Struct(item.value).(Errors); ok {
+			t.Errorf("%s: expect a tag error instead of Errors", item.name)
+		}
+	}
+}
+
+// TestCompileRecursiveError 自引用的类型中有tag写错时, 每个相关的类型都要返回错误, 而不是拿到编译了一半的rules
+func TestCompileRecursiveError(t *testing.T) {
+	// 先编译testLeft, 编译它的过程中testRight看到的是还没有编译完的testLeft
+	if err := Compile(reflect.TypeOf(testLeft{})); err == nil || !strings.Contains(err.Error(), "testLeft.Bad") {
+		t.Fatalf("expect the error of testLeft.Bad, got %v", err)
+	}
+
+	if err := Compile(reflect.TypeOf(&testRight{})); err == nil {
+		t.Fatal("testRight should also fail")
+	}
+
+	if err := Struct(&testRight{Left: &testLeft{}}); err == nil {
+		t.Fatal("Struct() should return the tag error")
+	}
+}
+
+func TestCompileNonStruct(t *testing.T) {
+	for _, typ := range []reflect.Type{nil, reflect.TypeOf(1), reflect.TypeOf([]byte(nil))} {
+		if err := Compile(typ); err != nil {
+			t.Errorf("%v: unexpected err=%v", typ, err)
+		}
+	}
+}